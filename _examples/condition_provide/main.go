@@ -1,7 +1,6 @@
 package main
 
 import (
-	"errors"
 	"fmt"
 	"log"
 	"net"
@@ -36,21 +35,21 @@ func NewUDPConn() *net.UDPConn {
 	return &net.UDPConn{}
 }
 
-// ProvideConfiguredConnection
-func ProvideConfiguredConnection(conf *Configuration, container *di.Container) error {
-	switch conf.ConnectionType {
-	case "tcp":
-		return container.Provide(NewTCPConn, di.As(new(net.Conn)))
-	case "udp":
-		return container.Provide(NewUDPConn, di.As(new(net.Conn)))
-	}
-	return errors.New("unknown connection type")
+// IsTCP checks that tcp connection is configured.
+func IsTCP(conf *Configuration) bool {
+	return conf.ConnectionType == "tcp"
+}
+
+// IsUDP checks that udp connection is configured.
+func IsUDP(conf *Configuration) bool {
+	return conf.ConnectionType == "udp"
 }
 
 func main() {
 	c, err := di.New(
 		di.Provide(NewConfiguration),
-		di.Invoke(ProvideConfiguredConnection),
+		di.When(IsTCP, di.Provide(NewTCPConn, di.As(new(net.Conn)))),
+		di.When(IsUDP, di.Provide(NewUDPConn, di.As(new(net.Conn)))),
 	)
 	if err != nil {
 		log.Fatalln(err)
@@ -0,0 +1,512 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/defval/di/cmd/internal/imports"
+)
+
+const (
+	visiting = 1
+	built    = 2
+)
+
+// errNotExists is a missing dependency error, optional fields are skipped on it.
+var errNotExists = errors.New("not exists in the container")
+
+// entry is a type registered in graph: provided type or interface of provider.
+type entry struct {
+	typ types.Type
+	p   *provider
+}
+
+// generator generates code that builds types of graph in dependency order.
+type generator struct {
+	*graph
+	entries []entry
+	imports *imports.Imports
+	body    bytes.Buffer
+	vars    int
+	// variables of structures with injected fields by type and tags
+	injected map[string]string
+}
+
+// generate generates function that builds graph like di.New() does: invocations are called in
+// declaration order, then targets are resolved. Only types required by them are built.
+func generate(g *graph, name string) ([]byte, error) {
+	gen := &generator{
+		graph:    g,
+		imports:  imports.New(g.pkg.Types),
+		injected: map[string]string{},
+	}
+	for _, p := range g.providers {
+		gen.entries = append(gen.entries, entry{typ: p.typ, p: p})
+		for _, i := range p.interfaces {
+			gen.entries = append(gen.entries, entry{typ: i, p: p})
+		}
+	}
+	for _, inv := range g.invocations {
+		if err := gen.invoke(inv); err != nil {
+			g.report(inv.pos, err)
+		}
+	}
+	for _, r := range g.resolutions {
+		if err := gen.resolve(r); err != nil {
+			g.report(r.pos, err)
+		}
+	}
+	if len(g.diagnostics) > 0 {
+		return nil, g.diagnosticsError()
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by di-gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", g.pkg.Name)
+	if gen.imports.Len() > 0 {
+		gen.imports.Write(&buf)
+	}
+	fn := buildFunc(name)
+	fmt.Fprintf(&buf, "\n// %s builds %s without reflection. The cleanup function must be called on shutdown.\n", fn, name)
+	fmt.Fprintf(&buf, "func %s() (cleanup func(), err error) {\n", fn)
+	fmt.Fprintf(&buf, "\tvar cleanups []func()\n")
+	fmt.Fprintf(&buf, "\tcleanup = func() {\n\t\tfor i := len(cleanups) - 1; i >= 0; i-- {\n\t\t\tcleanups[i]()\n\t\t}\n\t}\n")
+	buf.Write(gen.body.Bytes())
+	fmt.Fprintf(&buf, "\treturn cleanup, nil\n}\n")
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %w", err)
+	}
+	return src, nil
+}
+
+// posError is an error at position of provider declaration.
+type posError struct {
+	pos token.Pos
+	err error
+}
+
+func (e *posError) Error() string { return e.err.Error() }
+func (e *posError) Unwrap() error { return e.err }
+
+// report adds diagnostic of error at position of provider or at pos.
+func (g *graph) report(pos token.Pos, err error) {
+	var pe *posError
+	if errors.As(err, &pe) {
+		pos = pe.pos
+	}
+	g.errorf(pos, "%s", err)
+}
+
+// diagnosticsError returns error with diagnostics sorted by position.
+func (g *graph) diagnosticsError() error {
+	sort.SliceStable(g.diagnostics, func(i, j int) bool {
+		return g.diagnostics[i].pos < g.diagnostics[j].pos
+	})
+	lines := make([]string, 0, len(g.diagnostics))
+	for _, d := range g.diagnostics {
+		lines = append(lines, fmt.Sprintf("%s: %s", g.pkg.Fset.Position(d.pos), d.msg))
+	}
+	return errors.New(strings.Join(lines, "\n"))
+}
+
+// invoke generates invocation call.
+func (gen *generator) invoke(inv *invocation) error {
+	args, err := gen.args(inv.fn, inv.module, nil)
+	if err != nil {
+		return err
+	}
+	call := fmt.Sprintf("%s(%s)", gen.funcName(inv.fn), strings.Join(args, ", "))
+	if inv.fn.Type().(*types.Signature).Results().Len() == 0 {
+		fmt.Fprintf(&gen.body, "\t%s\n", call)
+		return nil
+	}
+	fmt.Fprintf(&gen.body, "\tif err := %s; err != nil {\n\t\treturn cleanup, err\n\t}\n", call)
+	return nil
+}
+
+// resolve generates assignment of resolved value to target.
+func (gen *generator) resolve(r *resolution) error {
+	value, err := gen.find(r.typ, r.tags, r.module, nil)
+	if err != nil {
+		return err
+	}
+	target, err := gen.expr(r.target)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(&gen.body, "\t%s = %s\n", target, value)
+	return nil
+}
+
+// args resolves arguments of function.
+func (gen *generator) args(fn *types.Func, module string, path []*provider) ([]string, error) {
+	params := fn.Type().(*types.Signature).Params()
+	args := make([]string, 0, params.Len())
+	for i := 0; i < params.Len(); i++ {
+		arg, err := gen.find(params.At(i).Type(), nil, module, path)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	if fn.Type().(*types.Signature).Variadic() && len(args) > 0 {
+		args[len(args)-1] += "..."
+	}
+	return args, nil
+}
+
+// find returns expression of type with tags visible from module like defaultSchema.find does.
+func (gen *generator) find(typ types.Type, tags map[string]string, module string, path []*provider) (string, error) {
+	var found, private []*provider
+	for _, e := range gen.entries {
+		if !types.Identical(e.typ, typ) || !matchTags(e.p.tags, tags) {
+			continue
+		}
+		if e.p.private && e.p.module != module {
+			private = append(private, e.p)
+			continue
+		}
+		found = append(found, e.p)
+	}
+	if len(found) > 1 && len(tags) == 0 {
+		var primaries []*provider
+		for _, p := range found {
+			if p.primary {
+				primaries = append(primaries, p)
+			}
+		}
+		if len(primaries) == 1 {
+			found = primaries
+		}
+	}
+	switch {
+	case len(found) == 1:
+		return gen.build(found[0], path)
+	case len(found) > 1:
+		return "", fmt.Errorf("multiple definitions of %s%s, maybe you need to use group type: []%s%s; candidates: %s",
+			gen.typeString(typ), tagsString(tags), gen.typeString(typ), tagsString(tags), gen.candidates(found))
+	case len(private) > 0:
+		return "", fmt.Errorf("type %s%s is private in module %s", gen.typeString(typ), tagsString(tags), private[0].module)
+	}
+	if slice, ok := typ.(*types.Slice); ok {
+		return gen.group(slice, tags, module, path)
+	}
+	if isInjectable(typ) {
+		return gen.inject(typ, tags, module, path)
+	}
+	return "", fmt.Errorf("type %s%s %w", gen.typeString(typ), tagsString(tags), errNotExists)
+}
+
+// group returns slice of group members.
+func (gen *generator) group(slice *types.Slice, tags map[string]string, module string, path []*provider) (string, error) {
+	var members []string
+	for _, e := range gen.entries {
+		if !types.Identical(e.typ, slice.Elem()) || !matchTags(e.p.tags, tags) {
+			continue
+		}
+		if e.p.private && e.p.module != module {
+			continue
+		}
+		member, err := gen.build(e.p, path)
+		if err != nil {
+			return "", err
+		}
+		members = append(members, member)
+	}
+	if len(members) == 0 {
+		return "", fmt.Errorf("type %s%s %w", gen.typeString(slice), tagsString(tags), errNotExists)
+	}
+	return fmt.Sprintf("%s{%s}", gen.imports.TypeString(slice), strings.Join(members, ", ")), nil
+}
+
+// inject creates structure with di.Inject and injects its fields.
+func (gen *generator) inject(typ types.Type, tags map[string]string, module string, path []*provider) (string, error) {
+	key := typ.String() + tagsString(tags) + module
+	if v, ok := gen.injected[key]; ok {
+		return v, nil
+	}
+	st := typ
+	ptr, isPtr := typ.(*types.Pointer)
+	if isPtr {
+		st = ptr.Elem()
+	}
+	values, err := gen.fields(typ, module, path)
+	if err != nil {
+		return "", err
+	}
+	fields := make([]string, 0, len(values))
+	for _, v := range values {
+		fields = append(fields, fmt.Sprintf("%s: %s", v.name, v.value))
+	}
+	name := gen.newVar()
+	lit := fmt.Sprintf("%s{%s}", gen.imports.TypeString(st), strings.Join(fields, ", "))
+	if isPtr {
+		lit = "&" + lit
+	}
+	fmt.Fprintf(&gen.body, "\t%s := %s\n", name, lit)
+	gen.injected[key] = name
+	return name, nil
+}
+
+// fieldValue is an expression of injected field.
+type fieldValue struct {
+	name  string
+	value string
+}
+
+// fields resolves injected fields of structure with di.Inject like populate does.
+func (gen *generator) fields(typ types.Type, module string, path []*provider) ([]fieldValue, error) {
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	s := typ.Underlying().(*types.Struct)
+	var values []fieldValue
+	for i := 0; i < s.NumFields(); i++ {
+		f := s.Field(i)
+		if !f.Exported() || f.Embedded() && (isDiType(f.Type(), "Inject") || isDiType(f.Type(), "Tags")) {
+			continue
+		}
+		ft, ok := parseField(s.Tag(i))
+		if !ok {
+			continue
+		}
+		value, err := gen.find(f.Type(), ft.tags, module, path)
+		if errors.Is(err, errNotExists) && ft.optional {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, fieldValue{name: f.Name(), value: value})
+	}
+	return values, nil
+}
+
+// populate generates assignments of injected fields of provided type after its construction.
+func (gen *generator) populate(p *provider, path []*provider) error {
+	if !isInjectable(p.typ) {
+		return nil
+	}
+	values, err := gen.fields(p.typ, p.module, path)
+	if err != nil {
+		return gen.at(p, err)
+	}
+	for _, v := range values {
+		fmt.Fprintf(&gen.body, "\t%s.%s = %s\n", p.name, v.name, v.value)
+	}
+	return nil
+}
+
+// at reports error of dependency at the innermost provider.
+func (gen *generator) at(p *provider, err error) error {
+	var pe *posError
+	if errors.As(err, &pe) {
+		return err
+	}
+	return &posError{pos: p.pos, err: fmt.Errorf("%s: %w", gen.typeString(p.typ), err)}
+}
+
+// build generates construction of provided type and returns its variable.
+func (gen *generator) build(p *provider, path []*provider) (string, error) {
+	switch p.state {
+	case built:
+		return p.name, nil
+	case visiting:
+		cycle := make([]string, 0, len(path)+1)
+		for _, n := range append(path, p) {
+			cycle = append(cycle, gen.typeString(n.typ))
+		}
+		return "", fmt.Errorf("cycle detected: %s", strings.Join(cycle, " -> "))
+	}
+	p.state = visiting
+	path = append(path, p)
+	defer func() {
+		if p.state == visiting {
+			p.state = 0
+		}
+	}()
+	if p.value != nil {
+		value, err := gen.expr(p.value)
+		if err != nil {
+			return "", err
+		}
+		p.name = gen.newVar()
+		fmt.Fprintf(&gen.body, "\t%s := %s\n", p.name, value)
+		if err := gen.populate(p, path); err != nil {
+			return "", err
+		}
+		p.state = built
+		return p.name, nil
+	}
+	args, err := gen.args(p.fn, p.module, path)
+	if err != nil {
+		return "", gen.at(p, err)
+	}
+	p.name = gen.newVar()
+	results := []string{p.name}
+	if p.cleanup {
+		results = append(results, p.name+"Cleanup")
+	}
+	if p.err {
+		results = append(results, "err")
+	}
+	fmt.Fprintf(&gen.body, "\t%s := %s(%s)\n", strings.Join(results, ", "), gen.funcName(p.fn), strings.Join(args, ", "))
+	if p.err {
+		fmt.Fprintf(&gen.body, "\tif err != nil {\n\t\treturn cleanup, err\n\t}\n")
+	}
+	if p.cleanup {
+		fmt.Fprintf(&gen.body, "\tcleanups = append(cleanups, %sCleanup)\n", p.name)
+	}
+	if err := gen.populate(p, path); err != nil {
+		return "", err
+	}
+	p.state = built
+	return p.name, nil
+}
+
+// expr returns source code of expression with package names of generated file.
+func (gen *generator) expr(expr ast.Expr) (string, error) {
+	var err error
+	ast.Inspect(expr, func(n ast.Node) bool {
+		ident, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		if pkg, ok := gen.pkg.TypesInfo.Uses[ident].(*types.PkgName); ok {
+			if name := gen.imports.Add(pkg.Imported().Path(), ident.Name); name != ident.Name && err == nil {
+				err = fmt.Errorf("%s: package name %s conflicts with another import", gen.text(expr), ident.Name)
+			}
+		}
+		return true
+	})
+	return gen.text(expr), err
+}
+
+// funcName returns qualified function name.
+func (gen *generator) funcName(fn *types.Func) string {
+	if pkg := gen.imports.Qualifier(fn.Pkg()); pkg != "" {
+		return pkg + "." + fn.Name()
+	}
+	return fn.Name()
+}
+
+func (gen *generator) newVar() string {
+	gen.vars++
+	return fmt.Sprintf("v%d", gen.vars)
+}
+
+// candidates lists providers with their positions.
+func (gen *generator) candidates(providers []*provider) string {
+	sources := make([]string, 0, len(providers))
+	for _, p := range providers {
+		sources = append(sources, fmt.Sprintf("%s%s at %s", gen.typeString(p.typ), tagsString(p.tags), gen.pkg.Fset.Position(p.pos)))
+	}
+	return strings.Join(sources, ", ")
+}
+
+// field is a parsed tag of injected field.
+type field struct {
+	tags     map[string]string
+	optional bool
+}
+
+// parseField parses tag of injected field like inspectStructField does. It returns false if field
+// is skipped.
+func parseField(tag string) (field, bool) {
+	f := field{tags: map[string]string{}}
+	pairs := parseTag(tag)
+	for _, kv := range pairs {
+		if kv[0] != "di" {
+			continue
+		}
+		for _, v := range strings.Split(kv[1], ",") {
+			switch v = strings.TrimSpace(v); {
+			case v == "":
+			case v == "skip":
+				return field{}, false
+			case v == "optional":
+				f.optional = true
+			default:
+				if k, value, ok := strings.Cut(v, "="); ok {
+					f.tags[k] = value
+				}
+			}
+		}
+		return f, true
+	}
+	// deprecated tag style
+	for _, kv := range pairs {
+		switch {
+		case kv[0] == "skip" && kv[1] == "true":
+			return field{}, false
+		case kv[0] == "optional":
+			f.optional = kv[1] == "true"
+		default:
+			f.tags[kv[0]] = kv[1]
+		}
+	}
+	return f, true
+}
+
+// isInjectable checks that typ is a structure or pointer to structure with embedded di.Inject.
+func isInjectable(typ types.Type) bool {
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	s, ok := typ.Underlying().(*types.Struct)
+	if !ok {
+		return false
+	}
+	for i := 0; i < s.NumFields(); i++ {
+		if s.Field(i).Embedded() && isDiType(s.Field(i).Type(), "Inject") {
+			return true
+		}
+	}
+	return false
+}
+
+// matchTags checks that provided tags match requested tags like Tags.match does.
+func matchTags(provided, requested map[string]string) bool {
+	for k, v := range requested {
+		pv, ok := provided[k]
+		if !ok || v != "*" && pv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// tagsString returns tags representation like Tags.String does.
+func tagsString(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		keys[i] = k + ":" + tags[k]
+	}
+	return "[" + strings.Join(keys, ";") + "]"
+}
+
+// buildFunc returns name of generated function.
+func buildFunc(name string) string {
+	return "build" + upperFirst(name)
+}
+
+func upperFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
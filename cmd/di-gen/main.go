@@ -0,0 +1,95 @@
+// Command di-gen generates code that builds container options without reflection.
+//
+// Add go:generate directive to the package with options declaration:
+//
+//	var App = di.Options(
+//		di.Provide(NewServer),
+//		di.Invoke(StartServer),
+//	)
+//
+//	//go:generate go run github.com/defval/di/cmd/di-gen -name App
+//
+// The declaration is a package variable initialized with di.Options() or di.Module(), or a function that
+// calls di.New(). The tool resolves dependencies with the container rules and generates function
+// buildApp() that calls constructors and invocations directly. Missing and ambiguous dependencies are
+// reported with positions of their declarations.
+//
+// Supported options are di.Provide(), di.ProvideValue(), di.Invoke(), di.Resolve(), di.Options() and
+// di.Module() with di.As(), di.WithName(), di.Tags, di.Primary(), di.Private() and di.Name(). Constructors
+// and invocations must be package functions.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/defval/di/cmd/internal/load"
+)
+
+var (
+	name   = flag.String("name", "", "name of options variable or function that calls di.New(); must be set")
+	output = flag.String("output", "", "output file name; default <name>_di.go")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage of di-gen:\n")
+	fmt.Fprintf(os.Stderr, "\tdi-gen -name N [-output file] [package]\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if *name == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	pattern := "."
+	if flag.NArg() > 0 {
+		pattern = flag.Arg(0)
+	}
+	pkg, err := load.Package(pattern, generated(*name, *output))
+	if err != nil {
+		fail(err)
+	}
+	g, err := parse(pkg, *name)
+	if err != nil {
+		fail(err)
+	}
+	src, err := generate(g, *name)
+	if err != nil {
+		fail(err)
+	}
+	file := *output
+	if file == "" {
+		file = filepath.Join(load.Dir(pkg), strings.ToLower(*name)+"_di.go")
+	}
+	if err := os.WriteFile(file, src, 0644); err != nil {
+		fail(err)
+	}
+}
+
+// generated accepts errors of code that uses generated function or of previously generated file.
+func generated(name, output string) func(packages.Error) bool {
+	undefined := "undefined: " + buildFunc(name)
+	return func(err packages.Error) bool {
+		if strings.Contains(err.Msg, undefined) {
+			return true
+		}
+		file := output
+		if file == "" {
+			file = strings.ToLower(name) + "_di.go"
+		}
+		return err.Kind == packages.TypeError && filepath.Base(strings.SplitN(err.Pos, ":", 2)[0]) == filepath.Base(file)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "di-gen: %s\n", err)
+	os.Exit(1)
+}
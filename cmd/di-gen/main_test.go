@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/defval/di"
+	"github.com/defval/di/cmd/di-gen/testdata/app"
+	"github.com/defval/di/cmd/internal/load"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Run("options variable", func(t *testing.T) {
+		pkg, err := load.Package("./testdata/app", generated("App", ""))
+		require.NoError(t, err)
+		g, err := parse(pkg, "App")
+		require.NoError(t, err)
+		src, err := generate(g, "App")
+		require.NoError(t, err)
+		golden, err := os.ReadFile("testdata/app/app_di.go")
+		require.NoError(t, err)
+		require.Equal(t, string(golden), string(src))
+	})
+
+	t.Run("generated code builds the same graph as container", func(t *testing.T) {
+		app.Server, app.Users = nil, nil
+		cleanup, err := app.Build()
+		require.NoError(t, err)
+		defer cleanup()
+		require.NotNil(t, app.Server)
+		generated := app.Server
+		require.NotNil(t, app.Users.Logger)
+		generatedLogger := app.Users.Logger
+
+		app.Server, app.Users = nil, nil
+		c, err := di.New(app.App)
+		require.NoError(t, err)
+		defer c.Cleanup()
+		require.NotNil(t, app.Server)
+		require.Equal(t, generated.Addr, app.Server.Addr)
+		require.Equal(t, generatedLogger, app.Users.Logger)
+	})
+
+	t.Run("diagnostics", func(t *testing.T) {
+		pkg, err := load.Package("./testdata/broken", generated("Setup", ""))
+		require.NoError(t, err)
+		g, err := parse(pkg, "Setup")
+		require.NoError(t, err)
+		_, err = generate(g, "Setup")
+		require.Error(t, err)
+		lines := strings.Split(err.Error(), "\n")
+		require.Len(t, lines, 2)
+		require.Contains(t, lines[0], "broken.go:32:3: func() string { return \":8080\" }: constructor must be a package function")
+		require.Contains(t, lines[1], "broken.go:33:3: *http.Server: multiple definitions of http.Handler, maybe you need to use group type: []http.Handler; candidates: *http.ServeMux at ")
+	})
+
+	t.Run("unknown declaration", func(t *testing.T) {
+		pkg, err := load.Package("./testdata/app", generated("Unknown", ""))
+		require.NoError(t, err)
+		_, err = parse(pkg, "Unknown")
+		require.Error(t, err)
+	})
+
+	t.Run("errors of generated code are ignored", func(t *testing.T) {
+		ignore := generated("App", "")
+		require.True(t, ignore(packages.Error{Msg: "undefined: buildApp"}))
+		require.True(t, ignore(packages.Error{Pos: "/src/app/app_di.go:10:2", Msg: "not enough arguments", Kind: packages.TypeError}))
+		require.False(t, ignore(packages.Error{Pos: "/src/app/app.go:10:2", Msg: "undefined: buildServer", Kind: packages.TypeError}))
+	})
+}
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/format"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+const diPath = "github.com/defval/di"
+
+// provider is a constructor or value provided with di.Provide() or di.ProvideValue().
+type provider struct {
+	pos token.Pos
+	// fn is a constructor, value is a value expression
+	fn    *types.Func
+	value ast.Expr
+	typ   types.Type
+	// interfaces registered with di.As()
+	interfaces []types.Type
+	tags       map[string]string
+	primary    bool
+	private    bool
+	module     string
+	// results of constructor
+	cleanup bool
+	err     bool
+	// name of variable in generated code, state of graph traversal
+	name  string
+	state int
+}
+
+// invocation is a function registered with di.Invoke().
+type invocation struct {
+	pos    token.Pos
+	fn     *types.Func
+	module string
+}
+
+// resolution is a target of di.Resolve().
+type resolution struct {
+	pos    token.Pos
+	target ast.Expr
+	typ    types.Type
+	tags   map[string]string
+	module string
+}
+
+// diagnostic is an error at position of declaration.
+type diagnostic struct {
+	pos token.Pos
+	msg string
+}
+
+// graph is a dependency graph parsed from options declaration.
+type graph struct {
+	pkg         *packages.Package
+	providers   []*provider
+	invocations []*invocation
+	resolutions []*resolution
+	diagnostics []diagnostic
+	// initializers of package variables and variables that are being parsed
+	vars    map[*types.Var]ast.Expr
+	parsing map[*types.Var]bool
+}
+
+// parse parses options declaration: package variable initialized with di.Options() or di.Module(), or
+// function that calls di.New().
+func parse(pkg *packages.Package, name string) (*graph, error) {
+	g := &graph{
+		pkg:     pkg,
+		vars:    map[*types.Var]ast.Expr{},
+		parsing: map[*types.Var]bool{},
+	}
+	var decl *ast.CallExpr
+	for _, file := range pkg.Syntax {
+		for _, d := range file.Decls {
+			switch d := d.(type) {
+			case *ast.GenDecl:
+				g.collectVars(d)
+			case *ast.FuncDecl:
+				if d.Recv == nil && d.Name.Name == name && d.Body != nil {
+					decl = g.findNew(d.Body)
+					if decl == nil {
+						return nil, fmt.Errorf("%s: function doesn't call di.New()", name)
+					}
+				}
+			}
+		}
+	}
+	if decl != nil {
+		for _, arg := range decl.Args {
+			g.option(arg, "")
+		}
+		return g, nil
+	}
+	v, ok := pkg.Types.Scope().Lookup(name).(*types.Var)
+	if !ok || g.vars[v] == nil {
+		return nil, fmt.Errorf("%s: options variable or function not found in package %s", name, pkg.Types.Path())
+	}
+	g.option(ast.NewIdent(name), "")
+	return g, nil
+}
+
+// collectVars collects initializers of package variables.
+func (g *graph) collectVars(decl *ast.GenDecl) {
+	for _, spec := range decl.Specs {
+		spec, ok := spec.(*ast.ValueSpec)
+		if !ok || len(spec.Names) != len(spec.Values) {
+			continue
+		}
+		for i, ident := range spec.Names {
+			if v, ok := g.pkg.TypesInfo.Defs[ident].(*types.Var); ok {
+				g.vars[v] = spec.Values[i]
+			}
+		}
+	}
+}
+
+// findNew finds di.New() call in function body.
+func (g *graph) findNew(body *ast.BlockStmt) (call *ast.CallExpr) {
+	ast.Inspect(body, func(n ast.Node) bool {
+		if c, ok := n.(*ast.CallExpr); ok && call == nil && g.diFunc(c.Fun) == "New" {
+			call = c
+		}
+		return call == nil
+	})
+	return call
+}
+
+// option parses container option.
+func (g *graph) option(expr ast.Expr, module string) {
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.Ident:
+		v, ok := g.pkg.Types.Scope().Lookup(e.Name).(*types.Var)
+		init := g.vars[v]
+		if !ok || init == nil {
+			g.errorf(e.Pos(), "%s: options must be declared with package variable", e.Name)
+			return
+		}
+		if g.parsing[v] {
+			g.errorf(e.Pos(), "%s: options include themselves", e.Name)
+			return
+		}
+		g.parsing[v] = true
+		g.option(init, module)
+		delete(g.parsing, v)
+	case *ast.CallExpr:
+		switch name := g.diFunc(e.Fun); name {
+		case "Options":
+			for _, arg := range e.Args {
+				g.option(arg, module)
+			}
+		case "Module":
+			name, ok := g.constant(e.Args[0])
+			if !ok {
+				g.errorf(e.Pos(), "module name must be a constant")
+				return
+			}
+			for _, arg := range e.Args[1:] {
+				g.option(arg, name)
+			}
+		case "Provide":
+			g.provide(e, module)
+		case "ProvideValue":
+			g.provideValue(e, module)
+		case "Invoke":
+			g.invoke(e, module)
+		case "Resolve":
+			g.resolve(e, module)
+		case "":
+			g.errorf(e.Pos(), "%s: unsupported option expression", g.text(e))
+		default:
+			g.errorf(e.Pos(), "di.%s() is not supported by di-gen", name)
+		}
+	default:
+		g.errorf(expr.Pos(), "%s: unsupported option expression", g.text(expr))
+	}
+}
+
+// provide parses di.Provide() option.
+func (g *graph) provide(call *ast.CallExpr, module string) {
+	fn := g.function(call.Args[0])
+	if fn == nil {
+		g.errorf(call.Pos(), "%s: constructor must be a package function", g.text(call.Args[0]))
+		return
+	}
+	sig := fn.Type().(*types.Signature)
+	p := &provider{
+		pos:    call.Pos(),
+		fn:     fn,
+		module: module,
+	}
+	results := sig.Results()
+	valid := results.Len() > 0
+	for i := 1; valid && i < results.Len(); i++ {
+		switch {
+		case isCleanup(results.At(i).Type()) && !p.cleanup && !p.err:
+			p.cleanup = true
+		case isError(results.At(i).Type()) && i == results.Len()-1:
+			p.err = true
+		default:
+			valid = false
+		}
+	}
+	if !valid || results.Len() > 3 {
+		g.errorf(call.Pos(), "invalid constructor signature, got %s", g.typeString(sig))
+		return
+	}
+	p.typ = results.At(0).Type()
+	p.tags = resultTags(p.typ)
+	g.provideOptions(p, call.Args[1:])
+}
+
+// provideValue parses di.ProvideValue() option.
+func (g *graph) provideValue(call *ast.CallExpr, module string) {
+	value := call.Args[0]
+	if local := g.local(value); local != "" {
+		g.errorf(call.Pos(), "%s: value uses local variable %s", g.text(value), local)
+		return
+	}
+	p := &provider{
+		pos:    call.Pos(),
+		value:  value,
+		typ:    g.pkg.TypesInfo.Types[value].Type,
+		tags:   map[string]string{},
+		module: module,
+	}
+	g.provideOptions(p, call.Args[1:])
+}
+
+// provideOptions parses provide options and registers provider.
+func (g *graph) provideOptions(p *provider, options []ast.Expr) {
+	for _, opt := range options {
+		if tags, ok := g.tags(opt); ok {
+			for k, v := range tags {
+				p.tags[k] = v
+			}
+			continue
+		}
+		call, ok := ast.Unparen(opt).(*ast.CallExpr)
+		if !ok {
+			g.errorf(opt.Pos(), "%s: unsupported provide option", g.text(opt))
+			continue
+		}
+		switch name := g.diFunc(call.Fun); name {
+		case "As":
+			for _, arg := range call.Args {
+				ptr, ok := g.pkg.TypesInfo.Types[arg].Type.(*types.Pointer)
+				if !ok || !types.IsInterface(ptr.Elem()) {
+					g.errorf(arg.Pos(), "%s: not a pointer to interface", g.text(arg))
+					continue
+				}
+				if !types.AssignableTo(p.typ, ptr.Elem()) {
+					g.errorf(arg.Pos(), "%s not implement %s", g.typeString(p.typ), g.typeString(ptr.Elem()))
+					continue
+				}
+				p.interfaces = append(p.interfaces, ptr.Elem())
+			}
+		case "WithName":
+			name, ok := g.constant(call.Args[0])
+			if !ok {
+				g.errorf(call.Pos(), "name must be a constant")
+				continue
+			}
+			p.tags["name"] = name
+		case "Primary":
+			p.primary = true
+		case "Private":
+			p.private = true
+		default:
+			g.errorf(call.Pos(), "%s: unsupported provide option", g.text(opt))
+		}
+	}
+	if p.private && p.module == "" {
+		g.errorf(p.pos, "%s: only types provided inside module can be private", g.typeString(p.typ))
+		return
+	}
+	g.providers = append(g.providers, p)
+}
+
+// invoke parses di.Invoke() option.
+func (g *graph) invoke(call *ast.CallExpr, module string) {
+	fn := g.function(call.Args[0])
+	if fn == nil {
+		g.errorf(call.Pos(), "%s: invocation must be a package function", g.text(call.Args[0]))
+		return
+	}
+	results := fn.Type().(*types.Signature).Results()
+	if results.Len() > 1 || results.Len() == 1 && !isError(results.At(0).Type()) {
+		g.errorf(call.Pos(), "invalid invocation signature, got %s", g.typeString(fn.Type()))
+		return
+	}
+	g.invocations = append(g.invocations, &invocation{
+		pos:    call.Pos(),
+		fn:     fn,
+		module: module,
+	})
+}
+
+// resolve parses di.Resolve() option.
+func (g *graph) resolve(call *ast.CallExpr, module string) {
+	target, ok := ast.Unparen(call.Args[0]).(*ast.UnaryExpr)
+	if !ok || target.Op != token.AND {
+		g.errorf(call.Pos(), "%s: target must be an address of variable", g.text(call.Args[0]))
+		return
+	}
+	if local := g.local(target.X); local != "" {
+		g.errorf(call.Pos(), "%s: target uses local variable %s", g.text(target.X), local)
+		return
+	}
+	r := &resolution{
+		pos:    call.Pos(),
+		target: target.X,
+		typ:    g.pkg.TypesInfo.Types[target.X].Type,
+		tags:   map[string]string{},
+		module: module,
+	}
+	for _, opt := range call.Args[1:] {
+		if tags, ok := g.tags(opt); ok {
+			for k, v := range tags {
+				r.tags[k] = v
+			}
+			continue
+		}
+		c, ok := ast.Unparen(opt).(*ast.CallExpr)
+		if !ok || g.diFunc(c.Fun) != "Name" {
+			g.errorf(opt.Pos(), "%s: unsupported resolve option", g.text(opt))
+			continue
+		}
+		name, ok := g.constant(c.Args[0])
+		if !ok {
+			g.errorf(c.Pos(), "name must be a constant")
+			continue
+		}
+		r.tags["name"] = name
+	}
+	g.resolutions = append(g.resolutions, r)
+}
+
+// diFunc returns name of di package function or empty string.
+func (g *graph) diFunc(expr ast.Expr) string {
+	sel, ok := ast.Unparen(expr).(*ast.SelectorExpr)
+	if !ok {
+		return ""
+	}
+	fn, ok := g.pkg.TypesInfo.Uses[sel.Sel].(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != diPath {
+		return ""
+	}
+	return fn.Name()
+}
+
+// function returns package function of expression or nil.
+func (g *graph) function(expr ast.Expr) *types.Func {
+	var ident *ast.Ident
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.Ident:
+		ident = e
+	case *ast.SelectorExpr:
+		ident = e.Sel
+	default:
+		return nil
+	}
+	fn, ok := g.pkg.TypesInfo.Uses[ident].(*types.Func)
+	if !ok || fn.Type().(*types.Signature).Recv() != nil || fn.Type().(*types.Signature).TypeParams().Len() > 0 {
+		return nil
+	}
+	return fn
+}
+
+// tags parses di.Tags composite literal with constant keys and values.
+func (g *graph) tags(expr ast.Expr) (map[string]string, bool) {
+	lit, ok := ast.Unparen(expr).(*ast.CompositeLit)
+	if !ok || !isDiType(g.pkg.TypesInfo.Types[lit].Type, "Tags") {
+		return nil, false
+	}
+	tags := map[string]string{}
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		k, kok := g.constant(kv.Key)
+		v, vok := g.constant(kv.Value)
+		if !kok || !vok {
+			g.errorf(elt.Pos(), "tags must be constants")
+			continue
+		}
+		tags[k] = v
+	}
+	return tags, true
+}
+
+// constant returns value of string constant expression.
+func (g *graph) constant(expr ast.Expr) (string, bool) {
+	tv := g.pkg.TypesInfo.Types[expr]
+	if tv.Value == nil || tv.Value.Kind() != constant.String {
+		return "", false
+	}
+	return constant.StringVal(tv.Value), true
+}
+
+// local returns name of local variable used in expression or empty string. Variables declared inside
+// the expression are allowed.
+func (g *graph) local(expr ast.Expr) (local string) {
+	ast.Inspect(expr, func(n ast.Node) bool {
+		ident, ok := n.(*ast.Ident)
+		if !ok || local != "" {
+			return local == ""
+		}
+		v, ok := g.pkg.TypesInfo.Uses[ident].(*types.Var)
+		if !ok || v.IsField() || v.Parent() == nil || v.Parent() == v.Pkg().Scope() {
+			return true
+		}
+		if v.Pos() < expr.Pos() || v.Pos() >= expr.End() {
+			local = v.Name()
+		}
+		return true
+	})
+	return local
+}
+
+// text returns source code of expression.
+func (g *graph) text(expr ast.Expr) string {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, g.pkg.Fset, expr); err != nil {
+		return fmt.Sprintf("%T", expr)
+	}
+	return buf.String()
+}
+
+// typeString returns type expression qualified by package names.
+func (g *graph) typeString(typ types.Type) string {
+	return types.TypeString(typ, func(pkg *types.Package) string {
+		return pkg.Name()
+	})
+}
+
+func (g *graph) errorf(pos token.Pos, format string, args ...interface{}) {
+	g.diagnostics = append(g.diagnostics, diagnostic{pos: pos, msg: fmt.Sprintf(format, args...)})
+}
+
+// resultTags parses tags of embedded di.Tags field of constructor result.
+func resultTags(typ types.Type) map[string]string {
+	tags := map[string]string{}
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	st, ok := typ.Underlying().(*types.Struct)
+	if !ok {
+		return tags
+	}
+	for i := 0; i < st.NumFields(); i++ {
+		if st.Field(i).Embedded() && isDiType(st.Field(i).Type(), "Tags") {
+			for _, kv := range parseTag(st.Tag(i)) {
+				tags[kv[0]] = kv[1]
+			}
+		}
+	}
+	return tags
+}
+
+// parseTag parses key:"value" pairs of struct tag.
+func parseTag(tag string) (pairs [][2]string) {
+	for tag != "" {
+		tag = strings.TrimLeft(tag, " ")
+		i := strings.Index(tag, ":\"")
+		if i <= 0 {
+			break
+		}
+		key := tag[:i]
+		tag = tag[i+1:]
+		j := 1
+		for j < len(tag) && tag[j] != '"' {
+			if tag[j] == '\\' {
+				j++
+			}
+			j++
+		}
+		if j >= len(tag) {
+			break
+		}
+		value, err := strconv.Unquote(tag[:j+1])
+		if err != nil {
+			break
+		}
+		pairs = append(pairs, [2]string{key, value})
+		tag = tag[j+1:]
+	}
+	return pairs
+}
+
+// isDiType checks that typ is a named type of di package.
+func isDiType(typ types.Type, name string) bool {
+	named, ok := typ.(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == diPath && named.Obj().Name() == name
+}
+
+// isError checks that typ is error.
+func isError(typ types.Type) bool {
+	return types.Identical(typ, types.Universe.Lookup("error").Type())
+}
+
+// isCleanup checks that typ is func().
+func isCleanup(typ types.Type) bool {
+	sig, ok := typ.Underlying().(*types.Signature)
+	return ok && sig.Params().Len() == 0 && sig.Results().Len() == 0
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/defval/di"
+)
+
+// Config is a configuration.
+type Config struct {
+	Addr string
+}
+
+// Handler is a named handler.
+type Handler interface {
+	http.Handler
+	Name() string
+}
+
+type handler string
+
+func (h handler) ServeHTTP(http.ResponseWriter, *http.Request) {}
+func (h handler) Name() string                                 { return string(h) }
+
+// NewUsersHandler creates users handler.
+func NewUsersHandler() handler { return "users" }
+
+// NewOrdersHandler creates orders handler.
+func NewOrdersHandler() handler { return "orders" }
+
+// NewMux creates mux with handlers.
+func NewMux(handlers []Handler) *http.ServeMux {
+	mux := http.NewServeMux()
+	for _, h := range handlers {
+		mux.Handle("/"+h.Name(), h)
+	}
+	return mux
+}
+
+// NewServer creates server.
+func NewServer(config *Config, handler http.Handler) (*http.Server, func(), error) {
+	server := &http.Server{Addr: config.Addr, Handler: handler}
+	return server, func() { server.Close() }, nil
+}
+
+// Logger is a logger.
+type Logger struct {
+	Prefix string
+}
+
+// NewLogger creates logger.
+func NewLogger() *Logger { return &Logger{Prefix: "app"} }
+
+// UsersService is a service with injected logger.
+type UsersService struct {
+	di.Inject
+	Logger *Logger
+}
+
+// NewUsersService creates users service, its logger is injected after construction.
+func NewUsersService() *UsersService { return &UsersService{} }
+
+// Params are injected parameters.
+type Params struct {
+	di.Inject
+	Server *http.Server
+	Closer io.Closer `di:"optional"`
+}
+
+// Start starts server.
+func Start(params Params) error {
+	return nil
+}
+
+// Unused is not built because nobody needs it.
+func Unused() io.Reader { return nil }
+
+// Server is a resolved server.
+var Server *http.Server
+
+// Users is a resolved users service.
+var Users *UsersService
+
+var handlers = di.Module("handlers",
+	di.Provide(NewUsersHandler, di.As(new(Handler)), di.WithName("users")),
+	di.Provide(NewOrdersHandler, di.As(new(Handler)), di.Tags{"name": "orders"}),
+)
+
+// App is an application options.
+var App = di.Options(
+	handlers,
+	di.ProvideValue(&Config{Addr: ":8080"}),
+	di.Provide(NewMux, di.As(new(http.Handler))),
+	di.Provide(NewServer),
+	di.Provide(Unused),
+	di.Provide(NewLogger),
+	di.Provide(NewUsersService),
+	di.Invoke(Start),
+	di.Resolve(&Server),
+	di.Resolve(&Users),
+)
+
+// Build builds application with generated code.
+func Build() (func(), error) {
+	return buildApp()
+}
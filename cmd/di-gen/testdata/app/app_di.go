@@ -0,0 +1,32 @@
+// Code generated by di-gen. DO NOT EDIT.
+
+package app
+
+// buildApp builds App without reflection. The cleanup function must be called on shutdown.
+func buildApp() (cleanup func(), err error) {
+	var cleanups []func()
+	cleanup = func() {
+		for i := len(cleanups) - 1; i >= 0; i-- {
+			cleanups[i]()
+		}
+	}
+	v1 := &Config{Addr: ":8080"}
+	v2 := NewUsersHandler()
+	v3 := NewOrdersHandler()
+	v4 := NewMux([]Handler{v2, v3})
+	v5, v5Cleanup, err := NewServer(v1, v4)
+	if err != nil {
+		return cleanup, err
+	}
+	cleanups = append(cleanups, v5Cleanup)
+	v6 := Params{Server: v5}
+	if err := Start(v6); err != nil {
+		return cleanup, err
+	}
+	Server = v5
+	v7 := NewUsersService()
+	v8 := NewLogger()
+	v7.Logger = v8
+	Users = v7
+	return cleanup, nil
+}
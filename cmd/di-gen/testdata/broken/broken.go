@@ -0,0 +1,37 @@
+package broken
+
+import (
+	"net/http"
+
+	"github.com/defval/di"
+)
+
+// NewServer creates server.
+func NewServer(handler http.Handler, addr string) *http.Server {
+	return &http.Server{Addr: addr, Handler: handler}
+}
+
+// NewFileServer creates file server.
+func NewFileServer() *FileServer {
+	return &FileServer{Handler: http.FileServer(http.Dir("."))}
+}
+
+// FileServer is a file server.
+type FileServer struct {
+	http.Handler
+}
+
+// Run runs server.
+func Run(server *http.Server) {}
+
+// Setup creates options.
+func Setup() error {
+	_, err := di.New(
+		di.Provide(http.NewServeMux, di.As(new(http.Handler))),
+		di.Provide(NewFileServer, di.As(new(http.Handler))),
+		di.Provide(func() string { return ":8080" }),
+		di.Provide(NewServer),
+		di.Invoke(Run),
+	)
+	return err
+}
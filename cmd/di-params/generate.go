@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// generate generates source code that registers parameter names of functions. If names are empty,
+// all package functions with named parameters are used.
+func generate(pkg *packages.Package, names []string) ([]byte, error) {
+	scope := pkg.Types.Scope()
+	var funcs []*types.Func
+	if len(names) == 0 {
+		// scope names are sorted
+		for _, name := range scope.Names() {
+			fn, ok := scope.Lookup(name).(*types.Func)
+			if ok && hasNamedParams(fn) {
+				funcs = append(funcs, fn)
+			}
+		}
+	}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		fn, ok := scope.Lookup(name).(*types.Func)
+		if !ok {
+			return nil, fmt.Errorf("%s: function not found in package %s", name, pkg.Types.Path())
+		}
+		if fn.Type().(*types.Signature).TypeParams().Len() > 0 {
+			return nil, fmt.Errorf("%s: generic functions are not supported", name)
+		}
+		funcs = append(funcs, fn)
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by di-params. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", pkg.Name)
+	fmt.Fprintf(&buf, "import \"github.com/defval/di\"\n\n")
+	fmt.Fprintf(&buf, "func init() {\n")
+	for _, fn := range funcs {
+		params := fn.Type().(*types.Signature).Params()
+		args := []string{fn.Name()}
+		for i := 0; i < params.Len(); i++ {
+			name := params.At(i).Name()
+			if name == "_" {
+				name = ""
+			}
+			args = append(args, fmt.Sprintf("%q", name))
+		}
+		fmt.Fprintf(&buf, "\tdi.ParamNames(%s)\n", strings.Join(args, ", "))
+	}
+	fmt.Fprintf(&buf, "}\n")
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %w", err)
+	}
+	return src, nil
+}
+
+// hasNamedParams checks that function is not generic and has named parameters.
+func hasNamedParams(fn *types.Func) bool {
+	sig := fn.Type().(*types.Signature)
+	if sig.TypeParams().Len() > 0 {
+		return false
+	}
+	for i := 0; i < sig.Params().Len(); i++ {
+		if name := sig.Params().At(i).Name(); name != "" && name != "_" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+// Command di-params records parameter names of functions for the container.
+//
+// Go reflection loses parameter names. Add go:generate directive to the package with constructors:
+//
+//	//go:generate go run github.com/defval/di/cmd/di-params
+//
+// The generated file registers parameter names of package functions with di.ParamNames(), the
+// container uses them to resolve parameters of types with several definitions.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/defval/di/cmd/internal/load"
+)
+
+var (
+	funcNames = flag.String("func", "", "comma-separated list of function names; default all functions with parameters")
+	output    = flag.String("output", "", "output file name; default di_params.go")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage of di-params:\n")
+	fmt.Fprintf(os.Stderr, "\tdi-params [-func F[,F...]] [-output file] [package]\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	pattern := "."
+	if flag.NArg() > 0 {
+		pattern = flag.Arg(0)
+	}
+	var funcs []string
+	if *funcNames != "" {
+		funcs = strings.Split(*funcNames, ",")
+	}
+	pkg, err := load.Package(pattern)
+	if err != nil {
+		fail(err)
+	}
+	src, err := generate(pkg, funcs)
+	if err != nil {
+		fail(err)
+	}
+	name := *output
+	if name == "" {
+		name = filepath.Join(load.Dir(pkg), "di_params.go")
+	}
+	if err := os.WriteFile(name, src, 0644); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "di-params: %s\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/defval/di/cmd/internal/load"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Run("functions with named parameters", func(t *testing.T) {
+		pkg, err := load.Package("./testdata/server")
+		require.NoError(t, err)
+		src, err := generate(pkg, nil)
+		require.NoError(t, err)
+		golden, err := os.ReadFile("testdata/server_params.golden")
+		require.NoError(t, err)
+		require.Equal(t, string(golden), string(src))
+	})
+
+	t.Run("selected functions", func(t *testing.T) {
+		pkg, err := load.Package("./testdata/server")
+		require.NoError(t, err)
+		src, err := generate(pkg, []string{"NewServer", "NewDefaultServer"})
+		require.NoError(t, err)
+		require.Contains(t, string(src), "\tdi.ParamNames(NewServer, \"addr\", \"metricsAddr\")\n\tdi.ParamNames(NewDefaultServer)\n")
+	})
+
+	t.Run("unknown or generic function", func(t *testing.T) {
+		pkg, err := load.Package("./testdata/server")
+		require.NoError(t, err)
+		_, err = generate(pkg, []string{"Server"})
+		require.EqualError(t, err, "Server: function not found in package github.com/defval/di/cmd/di-params/testdata/server")
+		_, err = generate(pkg, []string{"Map"})
+		require.EqualError(t, err, "Map: generic functions are not supported")
+	})
+}
@@ -0,0 +1,24 @@
+package server
+
+// Server is a server.
+type Server struct {
+	Addr        string
+	MetricsAddr string
+}
+
+// NewServer creates server.
+func NewServer(addr string, metricsAddr string) *Server {
+	return &Server{Addr: addr, MetricsAddr: metricsAddr}
+}
+
+// NewDefaultServer creates server with default addresses.
+func NewDefaultServer() *Server {
+	return NewServer(":8080", ":9090")
+}
+
+func register(_ *Server, name string) {}
+
+// Map is a generic function.
+func Map[T any](values []T, fn func(T) T) []T {
+	return values
+}
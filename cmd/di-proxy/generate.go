@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/types"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/defval/di/cmd/internal/imports"
+)
+
+const diPath = "github.com/defval/di"
+
+// generate generates source code of proxies of interfaces.
+func generate(pkg *packages.Package, names []string) ([]byte, error) {
+	g := &generator{
+		pkg:     pkg.Types,
+		imports: imports.New(pkg.Types),
+	}
+	g.imports.Add("reflect", "reflect")
+	g.imports.Add(diPath, "di")
+	for _, name := range names {
+		if err := g.proxy(strings.TrimSpace(name)); err != nil {
+			return nil, err
+		}
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by di-proxy. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", pkg.Name)
+	g.imports.Write(&buf)
+	buf.Write(g.buf.Bytes())
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %w", err)
+	}
+	return src, nil
+}
+
+// generator accumulates proxies and their imports.
+type generator struct {
+	pkg     *types.Package
+	buf     bytes.Buffer
+	imports *imports.Imports
+}
+
+// proxy generates proxy of named interface.
+func (g *generator) proxy(name string) error {
+	obj, ok := g.pkg.Scope().Lookup(name).(*types.TypeName)
+	if !ok {
+		return fmt.Errorf("%s: type not found in package %s", name, g.pkg.Path())
+	}
+	named, ok := obj.Type().(*types.Named)
+	if !ok {
+		return fmt.Errorf("%s: not a named type", name)
+	}
+	iface, ok := named.Underlying().(*types.Interface)
+	if !ok {
+		return fmt.Errorf("%s: not an interface", name)
+	}
+	if named.TypeParams().Len() > 0 {
+		return fmt.Errorf("%s: generic interfaces are not supported", name)
+	}
+	proxy := lowerFirst(name) + "Proxy"
+	fmt.Fprintf(&g.buf, "\nfunc init() {\n")
+	fmt.Fprintf(&g.buf, "\tdi.RegisterProxy(new(%s), func(handler di.ProxyHandler) interface{} {\n", name)
+	fmt.Fprintf(&g.buf, "\t\treturn %s(handler)\n", proxy)
+	fmt.Fprintf(&g.buf, "\t})\n}\n\n")
+	fmt.Fprintf(&g.buf, "// %s routes method calls of %s to the handler.\n", proxy, name)
+	fmt.Fprintf(&g.buf, "type %s di.ProxyHandler\n", proxy)
+	for i := 0; i < iface.NumMethods(); i++ {
+		method := iface.Method(i)
+		if !method.Exported() {
+			return fmt.Errorf("%s: unexported method %s is not supported", name, method.Name())
+		}
+		g.method(proxy, method.Name(), method.Type().(*types.Signature))
+	}
+	return nil
+}
+
+// method generates proxy method that passes arguments to the handler and converts its results.
+func (g *generator) method(proxy string, name string, sig *types.Signature) {
+	var params, args []string
+	for i := 0; i < sig.Params().Len(); i++ {
+		typ := sig.Params().At(i).Type()
+		typeString := g.imports.TypeString(typ)
+		if sig.Variadic() && i == sig.Params().Len()-1 {
+			typeString = "..." + g.imports.TypeString(typ.(*types.Slice).Elem())
+		}
+		params = append(params, fmt.Sprintf("a%d %s", i, typeString))
+		args = append(args, fmt.Sprintf("reflect.ValueOf(&a%d).Elem()", i))
+	}
+	var results, returns []string
+	for i := 0; i < sig.Results().Len(); i++ {
+		results = append(results, g.imports.TypeString(sig.Results().At(i).Type()))
+		returns = append(returns, fmt.Sprintf("r%d", i))
+	}
+	in := "nil"
+	if len(args) > 0 {
+		in = fmt.Sprintf("[]reflect.Value{%s}", strings.Join(args, ", "))
+	}
+	signature := fmt.Sprintf("%s(%s)", name, strings.Join(params, ", "))
+	switch len(results) {
+	case 0:
+	case 1:
+		signature += " " + results[0]
+	default:
+		signature += " (" + strings.Join(results, ", ") + ")"
+	}
+	fmt.Fprintf(&g.buf, "\nfunc (p %s) %s {\n", proxy, signature)
+	if len(results) == 0 {
+		fmt.Fprintf(&g.buf, "\tp(%q, %s)\n}\n", name, in)
+		return
+	}
+	fmt.Fprintf(&g.buf, "\tout := p(%q, %s)\n", name, in)
+	for i, result := range results {
+		fmt.Fprintf(&g.buf, "\tr%d, _ := out[%d].Interface().(%s)\n", i, i, result)
+	}
+	fmt.Fprintf(&g.buf, "\treturn %s\n}\n", strings.Join(returns, ", "))
+}
+
+func lowerFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[size:]
+}
@@ -0,0 +1,64 @@
+// Command di-proxy generates proxies of interfaces for di.Intercept().
+//
+// Add go:generate directive to the package with interface:
+//
+//	//go:generate go run github.com/defval/di/cmd/di-proxy -type Repository
+//
+// The generated file registers a proxy factory of each interface with di.RegisterProxy(), the
+// container uses it to route method calls through interceptors.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/defval/di/cmd/internal/load"
+)
+
+var (
+	typeNames = flag.String("type", "", "comma-separated list of interface names; must be set")
+	output    = flag.String("output", "", "output file name; default <type>_proxy.go")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage of di-proxy:\n")
+	fmt.Fprintf(os.Stderr, "\tdi-proxy -type T[,T...] [-output file] [package]\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	pattern := "."
+	if flag.NArg() > 0 {
+		pattern = flag.Arg(0)
+	}
+	types := strings.Split(*typeNames, ",")
+	pkg, err := load.Package(pattern)
+	if err != nil {
+		fail(err)
+	}
+	src, err := generate(pkg, types)
+	if err != nil {
+		fail(err)
+	}
+	name := *output
+	if name == "" {
+		name = filepath.Join(load.Dir(pkg), strings.ToLower(types[0])+"_proxy.go")
+	}
+	if err := os.WriteFile(name, src, 0644); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "di-proxy: %s\n", err)
+	os.Exit(1)
+}
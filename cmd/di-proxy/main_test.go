@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/defval/di/cmd/internal/load"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Run("proxy of interface", func(t *testing.T) {
+		pkg, err := load.Package("./testdata/repo")
+		require.NoError(t, err)
+		src, err := generate(pkg, []string{"Repository"})
+		require.NoError(t, err)
+		golden, err := os.ReadFile("testdata/repository_proxy.golden")
+		require.NoError(t, err)
+		require.Equal(t, string(golden), string(src))
+	})
+
+	t.Run("not an interface", func(t *testing.T) {
+		pkg, err := load.Package("./testdata/repo")
+		require.NoError(t, err)
+		_, err = generate(pkg, []string{"User"})
+		require.EqualError(t, err, "User: not an interface")
+	})
+
+	t.Run("unknown type", func(t *testing.T) {
+		pkg, err := load.Package("./testdata/repo")
+		require.NoError(t, err)
+		_, err = generate(pkg, []string{"Unknown"})
+		require.EqualError(t, err, "Unknown: type not found in package github.com/defval/di/cmd/di-proxy/testdata/repo")
+	})
+}
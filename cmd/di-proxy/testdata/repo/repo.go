@@ -0,0 +1,19 @@
+package repo
+
+import (
+	"context"
+	"io"
+)
+
+// User is a user.
+type User struct {
+	ID int
+}
+
+// Repository is a repository of users.
+type Repository interface {
+	io.Closer
+	Get(ctx context.Context, id int) (*User, error)
+	Find(ctx context.Context, ids ...int) []*User
+	Save(users map[int]*User)
+}
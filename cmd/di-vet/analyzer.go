@@ -0,0 +1,222 @@
+package main
+
+import (
+	"go/ast"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+const diPath = "github.com/defval/di"
+
+// Analyzer reports mistakes in usage of the di package.
+var Analyzer = &analysis.Analyzer{
+	Name:     "di",
+	Doc:      "report mistakes in usage of github.com/defval/di",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+// deprecated contains replacements of deprecated functions.
+var deprecated = map[string]string{
+	"WithName": `di.Tags{"name": ...}`,
+	"Name":     `di.Tags{"name": ...}`,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	ins := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	filter := []ast.Node{(*ast.CallExpr)(nil), (*ast.StructType)(nil)}
+	ins.Preorder(filter, func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			checkCall(pass, n)
+		case *ast.StructType:
+			checkTags(pass, n)
+		}
+	})
+	return nil, nil
+}
+
+// checkCall checks call of function or method of di package.
+func checkCall(pass *analysis.Pass, call *ast.CallExpr) {
+	fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+	if !ok || !isDi(fn) {
+		return
+	}
+	sig := fn.Type().(*types.Signature)
+	if replacement, ok := deprecated[fn.Name()]; ok && sig.Recv() == nil {
+		pass.Reportf(call.Pos(), "di.%s is deprecated: use %s", fn.Name(), replacement)
+	}
+	if fn.Name() == "As" && sig.Recv() == nil {
+		for _, arg := range call.Args {
+			if _, ok := interfacePointer(pass, arg); !ok {
+				pass.Reportf(arg.Pos(), "di.As: %s is not a pointer to interface", typeString(typeOf(pass, arg)))
+			}
+		}
+		return
+	}
+	for i, arg := range call.Args {
+		switch paramType(sig, i, call.Ellipsis.IsValid()) {
+		case "Pointer":
+			checkPointer(pass, fn, arg)
+		case "Invocation":
+			checkInvocation(pass, arg)
+		case "Constructor":
+			if sig, ok := typeOf(pass, arg).Underlying().(*types.Signature); ok && sig.Results().Len() > 0 {
+				checkInterfaces(pass, sig.Results().At(0).Type(), call.Args[i+1:])
+			}
+		case "Value":
+			checkInterfaces(pass, typeOf(pass, arg), call.Args[i+1:])
+		}
+	}
+}
+
+// checkPointer checks that argument is a pointer.
+func checkPointer(pass *analysis.Pass, fn *types.Func, arg ast.Expr) {
+	typ := typeOf(pass, arg)
+	if types.IsInterface(typ) {
+		return
+	}
+	if _, ok := typ.Underlying().(*types.Pointer); !ok {
+		pass.Reportf(arg.Pos(), "%s: target must be a pointer, got %s", funcName(fn), typeString(typ))
+	}
+}
+
+// checkInvocation checks that invocation is a function that returns nothing or error.
+func checkInvocation(pass *analysis.Pass, arg ast.Expr) {
+	typ := typeOf(pass, arg)
+	if types.IsInterface(typ) {
+		return
+	}
+	sig, ok := typ.Underlying().(*types.Signature)
+	if !ok {
+		pass.Reportf(arg.Pos(), "invalid invocation signature, got %s", typeString(typ))
+		return
+	}
+	results := sig.Results()
+	if results.Len() == 0 || results.Len() == 1 && isError(results.At(0).Type()) {
+		return
+	}
+	pass.Reportf(arg.Pos(), "invalid invocation signature, got %s: invocation may return only error", typeString(typ))
+}
+
+// checkInterfaces checks that provided type implements interfaces of di.As() options.
+func checkInterfaces(pass *analysis.Pass, typ types.Type, options []ast.Expr) {
+	for _, opt := range options {
+		if isDiCall(pass, opt, "Flatten") {
+			if slice, ok := typ.Underlying().(*types.Slice); ok {
+				typ = slice.Elem()
+			}
+		}
+	}
+	for _, opt := range options {
+		if !isDiCall(pass, opt, "As") {
+			continue
+		}
+		for _, arg := range opt.(*ast.CallExpr).Args {
+			iface, ok := interfacePointer(pass, arg)
+			if ok && !types.Implements(typ, iface) {
+				pass.Reportf(arg.Pos(), "%s does not implement %s", typeString(typ), typeString(typeOf(pass, arg).(*types.Pointer).Elem()))
+			}
+		}
+	}
+}
+
+// checkTags checks that di tags of struct fields are valid.
+func checkTags(pass *analysis.Pass, st *ast.StructType) {
+	for _, field := range st.Fields.List {
+		if field.Tag == nil {
+			continue
+		}
+		tag, err := strconv.Unquote(field.Tag.Value)
+		if err != nil {
+			continue
+		}
+		value, ok := reflect.StructTag(tag).Lookup("di")
+		if !ok || value == "" {
+			continue
+		}
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			if part == "skip" || part == "optional" || strings.Contains(part, "=") {
+				continue
+			}
+			pass.Reportf(field.Tag.Pos(), "invalid di tag: skip, optional or key=value expected, got %q", part)
+		}
+	}
+}
+
+// paramType returns name of di type of i-th parameter.
+func paramType(sig *types.Signature, i int, ellipsis bool) string {
+	params := sig.Params()
+	var typ types.Type
+	switch {
+	case sig.Variadic() && i >= params.Len()-1:
+		if ellipsis {
+			return ""
+		}
+		typ = params.At(params.Len() - 1).Type().(*types.Slice).Elem()
+	case i < params.Len():
+		typ = params.At(i).Type()
+	default:
+		return ""
+	}
+	named, ok := typ.(*types.Named)
+	if !ok || !isDi(named.Obj()) {
+		return ""
+	}
+	return named.Obj().Name()
+}
+
+// interfacePointer returns interface if expression is a pointer to interface.
+func interfacePointer(pass *analysis.Pass, expr ast.Expr) (*types.Interface, bool) {
+	ptr, ok := typeOf(pass, expr).(*types.Pointer)
+	if !ok {
+		return nil, false
+	}
+	iface, ok := ptr.Elem().Underlying().(*types.Interface)
+	return iface, ok
+}
+
+// isDiCall checks that expression is a call of function of di package.
+func isDiCall(pass *analysis.Pass, expr ast.Expr, name string) bool {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+	return ok && isDi(fn) && fn.Name() == name && fn.Type().(*types.Signature).Recv() == nil
+}
+
+func funcName(fn *types.Func) string {
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+		return "container." + fn.Name()
+	}
+	return "di." + fn.Name()
+}
+
+func typeOf(pass *analysis.Pass, expr ast.Expr) types.Type {
+	if typ := pass.TypesInfo.TypeOf(expr); typ != nil {
+		return typ
+	}
+	return types.Typ[types.Invalid]
+}
+
+// typeString formats type with package names like reflect does.
+func typeString(typ types.Type) string {
+	return types.TypeString(typ, (*types.Package).Name)
+}
+
+func isDi(obj types.Object) bool {
+	return obj.Pkg() != nil && obj.Pkg().Path() == diPath
+}
+
+func isError(typ types.Type) bool {
+	return types.Identical(typ, types.Universe.Lookup("error").Type())
+}
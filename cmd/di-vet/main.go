@@ -0,0 +1,19 @@
+// Command di-vet reports mistakes in usage of the di package that are detectable statically.
+//
+// Run it with go vet:
+//
+//	go install github.com/defval/di/cmd/di-vet
+//	go vet -vettool=$(which di-vet) ./...
+//
+// It reports non-pointer targets of di.Resolve() and similar functions, di.As() with types that
+// don't implement the interface, malformed di struct tags, usage of deprecated di.WithName() and
+// di.Name(), and invocations with invalid signature.
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/unitchecker"
+)
+
+func main() {
+	unitchecker.Main(Analyzer)
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/defval/di"
+)
+
+// Server is a server.
+type Server struct{}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(http.ResponseWriter, *http.Request) {}
+
+// NewServer creates server.
+func NewServer() *Server { return &Server{} }
+
+// NewServers creates servers.
+func NewServers() []*Server { return nil }
+
+// Params are injected parameters.
+type Params struct {
+	di.Inject
+	Server   *Server      `di:"name=server,optional"`
+	Handler  http.Handler `di:"optional,server"` // want `invalid di tag: skip, optional or key=value expected, got "server"`
+	Optional io.Reader    `di:""`
+}
+
+func options() di.Option {
+	var server *Server
+	var handler http.Handler
+	var target interface{} = &server
+	return di.Options(
+		di.Provide(NewServer, di.As(new(http.Handler))),
+		di.Provide(NewServer, di.As(new(io.Reader))), // want `\*app.Server does not implement io.Reader`
+		di.Provide(NewServer, di.As(Server{})),       // want `di.As: app.Server is not a pointer to interface`
+		di.Provide(NewServers, di.As(new(http.Handler)), di.Flatten()),
+		di.ProvideValue(&Server{}, di.As(new(io.Closer))), // want `\*app.Server does not implement io.Closer`
+		di.Provide(NewServer, di.WithName("server")),      // want `di.WithName is deprecated: use di.Tags{"name": ...}`
+		di.Resolve(&server),
+		di.Resolve(*server), // want `di.Resolve: target must be a pointer, got app.Server`
+		di.Resolve(handler),
+		di.Resolve(target),
+		di.Invoke(func(s *Server) error { return nil }),
+		di.Invoke(func(s *Server) {}),
+		di.Invoke(func(s *Server) *Server { return s }), // want `invalid invocation signature, got func\(s \*app.Server\) \*app.Server: invocation may return only error`
+	)
+}
+
+func container(c *di.Container) {
+	var server Server
+	_ = c.Resolve(server, di.Name("server")) // want `container.Resolve: target must be a pointer, got app.Server` `di.Name is deprecated`
+	_ = c.Invoke("start")                    // want `invalid invocation signature, got string`
+	_, _ = c.Has(&server)
+}
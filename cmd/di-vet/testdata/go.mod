@@ -0,0 +1,7 @@
+module example.com/vet
+
+go 1.22
+
+require github.com/defval/di v1.12.0
+
+replace github.com/defval/di => ../../..
@@ -0,0 +1,19 @@
+module github.com/defval/di/cmd
+
+go 1.22.0
+
+require (
+	github.com/stretchr/testify v1.8.2
+	golang.org/x/tools v0.30.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/defval/di v1.12.0
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/mod v0.23.0 // indirect
+	golang.org/x/sync v0.11.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/defval/di => ../
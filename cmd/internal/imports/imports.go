@@ -0,0 +1,91 @@
+// Package imports collects imports of generated code.
+package imports
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Imports maps import paths of generated file to package names. Conflicting names are aliased.
+type Imports struct {
+	pkg *types.Package
+	// paths maps import path to package name, names maps package name to import path
+	paths map[string]string
+	names map[string]string
+}
+
+// New creates imports of generated file in the package.
+func New(pkg *types.Package) *Imports {
+	return &Imports{
+		pkg:   pkg,
+		paths: map[string]string{},
+		names: map[string]string{},
+	}
+}
+
+// Add adds import and returns its package name. If name is already used by another
+// package, an alias is returned.
+func (i *Imports) Add(importPath, name string) string {
+	if current, ok := i.paths[importPath]; ok {
+		return current
+	}
+	alias := name
+	for n := 1; i.names[alias] != ""; n++ {
+		alias = fmt.Sprintf("%s%d", name, n)
+	}
+	i.paths[importPath] = alias
+	i.names[alias] = importPath
+	return alias
+}
+
+// Qualifier returns package name for types.TypeString() and adds package to imports.
+func (i *Imports) Qualifier(pkg *types.Package) string {
+	if pkg == i.pkg {
+		return ""
+	}
+	return i.Add(pkg.Path(), pkg.Name())
+}
+
+// TypeString returns type expression and adds its packages to imports.
+func (i *Imports) TypeString(typ types.Type) string {
+	return types.TypeString(typ, i.Qualifier)
+}
+
+// Len returns count of imports.
+func (i *Imports) Len() int {
+	return len(i.paths)
+}
+
+// Write writes import declaration. Standard packages go first.
+func (i *Imports) Write(buf *bytes.Buffer) {
+	paths := make([]string, 0, len(i.paths))
+	for p := range i.paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	sort.SliceStable(paths, func(a, b int) bool {
+		return isStd(paths[a]) && !isStd(paths[b])
+	})
+	fmt.Fprintf(buf, "import (\n")
+	for n, p := range paths {
+		if n > 0 && isStd(paths[n-1]) && !isStd(p) {
+			fmt.Fprintf(buf, "\n")
+		}
+		name := i.paths[p]
+		if name == path.Base(p) {
+			fmt.Fprintf(buf, "\t%q\n", p)
+		} else {
+			fmt.Fprintf(buf, "\t%s %q\n", name, p)
+		}
+	}
+	fmt.Fprintf(buf, ")\n")
+}
+
+// isStd checks that import path is a standard package.
+func isStd(importPath string) bool {
+	return !strings.Contains(strings.Split(importPath, "/")[0], ".")
+}
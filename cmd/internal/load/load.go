@@ -0,0 +1,44 @@
+// Package load loads packages for code generators.
+package load
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Package loads type-checked package with syntax. Errors accepted by ignore functions don't fail loading,
+// this allows to load package that uses not yet generated code.
+func Package(pattern string, ignore ...func(packages.Error) bool) (*packages.Package, error) {
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedTypes | packages.NeedTypesInfo |
+			packages.NeedSyntax | packages.NeedImports | packages.NeedDeps,
+	}
+	pkgs, err := packages.Load(cfg, pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("%s: expected one package, got %d", pattern, len(pkgs))
+	}
+	pkg := pkgs[0]
+errors:
+	for _, err := range pkg.Errors {
+		for _, fn := range ignore {
+			if fn(err) {
+				continue errors
+			}
+		}
+		return nil, err
+	}
+	return pkg, nil
+}
+
+// Dir returns directory of package.
+func Dir(pkg *packages.Package) string {
+	if len(pkg.GoFiles) == 0 {
+		return "."
+	}
+	return filepath.Dir(pkg.GoFiles[0])
+}
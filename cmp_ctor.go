@@ -1,7 +1,10 @@
 package di
 
 import (
+	"context"
 	"reflect"
+	"runtime/debug"
+	"time"
 )
 
 // ctorType describes types of constructor provider.
@@ -19,6 +22,11 @@ const (
 type constructorCompiler struct {
 	typ ctorType
 	fn  function
+	// names of parameters, see ParamNames()
+	names []string
+	// timeout of constructor call and retry policy, see Timeout() and Retry()
+	timeout time.Duration
+	retry   *RetryPolicy
 }
 
 // newConstructorCompiler creates new function compiler from function.
@@ -28,17 +36,18 @@ func newConstructorCompiler(fn function) (*constructorCompiler, bool) {
 		return nil, false
 	}
 	return &constructorCompiler{
-		typ: ctorType,
-		fn:  fn,
+		typ:   ctorType,
+		fn:    fn,
+		names: paramNames(fn),
 	}, true
 }
 
 func (c constructorCompiler) deps(s schema) (deps []*node, err error) {
 	for i := 0; i < c.fn.NumIn(); i++ {
 		in := c.fn.Type.In(i)
-		node, err := s.find(in, Tags{})
+		node, err := findParam(s, in, paramName(c.names, i))
 		if err != nil {
-			return nil, err
+			return nil, withParam(err, i)
 		}
 		deps = append(deps, node)
 	}
@@ -46,6 +55,149 @@ func (c constructorCompiler) deps(s schema) (deps []*node, err error) {
 }
 
 func (c constructorCompiler) compile(dependencies []reflect.Value, s schema) (reflect.Value, error) {
+	if c.timeout == 0 && c.retry == nil {
+		return c.call(dependencies, s)
+	}
+	attempts := 1
+	if c.retry != nil && c.retry.Attempts > 1 {
+		attempts = c.retry.Attempts
+	}
+	for attempt := 1; ; attempt++ {
+		rv, err := c.attempt(dependencies, s)
+		if err == nil {
+			return rv, nil
+		}
+		if c.retry == nil {
+			return reflect.Value{}, err
+		}
+		if attempt == attempts {
+			return reflect.Value{}, &RetryError{Attempts: attempt, Err: err}
+		}
+		tracer.Trace("Attempt %d of %s failed: %s", attempt, c.fn.Name, err)
+		if err := sleep(resolutionContext(s), c.retry.delay(attempt)); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+}
+
+// attempt calls constructor with timeout. Constructor parameters of context.Context type are cancelled
+// when the timeout is exceeded or the call fails, the context of successful call stays alive. The
+// constructor that exceeds the timeout keeps running in background, its late result is cleaned up. The
+// result of failed call is cleaned up.
+func (c constructorCompiler) attempt(dependencies []reflect.Value, s schema) (reflect.Value, error) {
+	if c.timeout == 0 {
+		return c.finish(c.results(c.fn.Call(dependencies)), s)
+	}
+	args := make([]reflect.Value, len(dependencies))
+	var cancels []context.CancelFunc
+	for i, dep := range dependencies {
+		args[i] = dep
+		if c.fn.In(i) != contextInterface {
+			continue
+		}
+		ctx, cancel := context.WithCancel(dep.Interface().(context.Context))
+		cancels = append(cancels, cancel)
+		args[i] = reflect.ValueOf(&ctx).Elem()
+	}
+	cancel := func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}
+	base, _ := unscope(s)
+	done := make(chan ctorResult, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- ctorResult{panic: &ctorPanic{value: r, stack: debug.Stack()}}
+			}
+		}()
+		done <- c.results(c.fn.Call(args))
+	}()
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	select {
+	case result := <-done:
+		if result.panic != nil {
+			cancel()
+			if base.recoverPanics {
+				panic(result.panic)
+			}
+			panic(result.panic.value)
+		}
+		if result.err != nil {
+			cancel()
+		}
+		return c.finish(result, s)
+	case <-timer.C:
+		cancel()
+		go c.late(done, base.recoverPanics)
+		return reflect.Value{}, &TimeoutError{Timeout: c.timeout}
+	}
+}
+
+// late cleans up the result of constructor that exceeded the timeout. The late panic is traced if the
+// container recovers panics, otherwise it is raised again.
+func (c constructorCompiler) late(done <-chan ctorResult, recoverPanics bool) {
+	result := <-done
+	if result.panic != nil {
+		if !recoverPanics {
+			panic(result.panic.value)
+		}
+		tracer.Trace("Recovered panic in %s after timeout: %v\n%s", c.fn.Name, result.panic.value, result.panic.stack)
+		return
+	}
+	if result.cleanup != nil {
+		tracer.Trace("Clean up late result of %s", c.fn.Name)
+		result.cleanup()
+	}
+}
+
+// ctorResult is a result of constructor call.
+type ctorResult struct {
+	value   reflect.Value
+	cleanup func()
+	err     error
+	panic   *ctorPanic
+}
+
+// ctorPanic is a panic recovered in goroutine of constructor call with the stack of the goroutine.
+type ctorPanic struct {
+	value interface{}
+	stack []byte
+}
+
+// results parses results of constructor call.
+func (c constructorCompiler) results(values []reflect.Value) ctorResult {
+	out := funcResult(values)
+	result := ctorResult{value: out.value()}
+	switch c.typ {
+	case ctorValueError:
+		result.err = out.error(1)
+	case ctorValueCleanup:
+		result.cleanup = out.cleanup()
+	case ctorValueCleanupError:
+		result.cleanup, result.err = out.cleanup(), out.error(2)
+	}
+	return result
+}
+
+// finish registers cleanup of successful call or cleans up the result of failed call.
+func (c constructorCompiler) finish(result ctorResult, s schema) (reflect.Value, error) {
+	if result.err != nil {
+		if result.cleanup != nil {
+			result.cleanup()
+		}
+		return reflect.Value{}, result.err
+	}
+	if result.cleanup != nil {
+		s.cleanup(result.cleanup)
+	}
+	return result.value, nil
+}
+
+// call calls constructor function.
+func (c constructorCompiler) call(dependencies []reflect.Value, s schema) (reflect.Value, error) {
 	// call constructor function
 	out := funcResult(c.fn.Call(dependencies))
 	rv := out.value()
@@ -1,19 +1,34 @@
 package di
 
 import (
+	"fmt"
 	"reflect"
 )
 
+// groupTag is a tag that selects members of named group, it's namespaced to not clash with tags of
+// group members.
+const groupTag = "di.group"
+
+// mapGroupKey is a tag that specifies which tag of group members is used as map key.
+const mapGroupKey = "key"
+
+// defaultMapGroupKey is a tag of group members that used as map key by default.
+const defaultMapGroupKey = "name"
+
 type groupCompiler struct {
 	rt      reflect.Type
 	matched []*node
+	// key is a tag of matched nodes that used as map key
+	key string
 }
 
-// newGroupCompiler creates group compiler of rt and with matched nodes.
-func newGroupCompiler(rt reflect.Type, matched []*node) *groupCompiler {
+// newGroupCompiler creates group compiler of rt and with matched nodes. If rt is a map,
+// the key is a tag of matched nodes which values are used as map keys.
+func newGroupCompiler(rt reflect.Type, matched []*node, key string) *groupCompiler {
 	return &groupCompiler{
 		rt:      rt,
 		matched: matched,
+		key:     key,
 	}
 }
 
@@ -22,5 +37,53 @@ func (c *groupCompiler) deps(s schema) (deps []*node, err error) {
 }
 
 func (c *groupCompiler) compile(dependencies []reflect.Value, s schema) (reflect.Value, error) {
-	return reflect.Append(reflect.New(c.rt).Elem(), dependencies...), nil
+	if c.rt.Kind() == reflect.Map {
+		group := reflect.MakeMapWithSize(c.rt, len(dependencies))
+		for i, dep := range dependencies {
+			n := c.matched[i]
+			key := reflect.ValueOf(n.tags[c.key]).Convert(c.rt.Key())
+			for _, elem := range n.elements(dep) {
+				if group.MapIndex(key).IsValid() {
+					return reflect.Value{}, fmt.Errorf("duplicate key %s:%s: %s", c.key, n.tags[c.key], n.source())
+				}
+				group.SetMapIndex(key, elem)
+			}
+		}
+		return group, nil
+	}
+	var elements []reflect.Value
+	for i, dep := range dependencies {
+		elements = append(elements, c.matched[i].elements(dep)...)
+	}
+	return reflect.Append(reflect.MakeSlice(c.rt, 0, len(elements)), elements...), nil
+}
+
+// isMapGroup checks that t is a map group type: map with string keys.
+func isMapGroup(t reflect.Type) bool {
+	return t.Kind() == reflect.Map && t.Key().Kind() == reflect.String
+}
+
+// mapGroupTags returns tags that select map group members and the tag used as map key.
+func mapGroupTags(tags Tags) (Tags, string) {
+	key := defaultMapGroupKey
+	if k, ok := tags[mapGroupKey]; ok {
+		key = k
+	}
+	filter := tags.without(mapGroupKey)
+	if _, ok := filter[key]; !ok {
+		filter[key] = "*"
+	}
+	return filter, key
+}
+
+// checkGroupKeys checks that map group members have unique keys.
+func checkGroupKeys(nodes []*node, key string) error {
+	keys := make(map[string]*node, len(nodes))
+	for _, n := range nodes {
+		if prev, ok := keys[n.tags[key]]; ok {
+			return fmt.Errorf("duplicate key %s:%s: %s", key, n.tags[key], candidates([]*node{prev, n}))
+		}
+		keys[n.tags[key]] = n
+	}
+	return nil
 }
@@ -0,0 +1,132 @@
+package di
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// condition decides whether types, decorators, invocations and resolves of conditional options are enabled.
+// Types and decorators of conditional options are registered regardless of the condition, the condition of
+// di.When() is evaluated on the first lookup of them and its result is kept.
+type condition struct {
+	frame callerFrame
+	// fn of di.When() condition, its dependencies are resolved from the module
+	fn     *function
+	schema *defaultSchema
+	module *module
+	// parent is a condition of enclosing conditional options
+	parent *condition
+	// mu guards result of evaluation
+	mu        sync.Mutex
+	evaluated bool
+	value     bool
+}
+
+// evaluation is a chain of conditions which dependencies are resolved in the scope.
+type evaluation struct {
+	cond *condition
+	prev *evaluation
+}
+
+// has checks that dependencies of condition are being resolved.
+func (e *evaluation) has(cond *condition) bool {
+	for ; e != nil; e = e.prev {
+		if e.cond == cond {
+			return true
+		}
+	}
+	return false
+}
+
+// newCondition creates condition of conditional options applied to schema.
+func newCondition(s *defaultSchema, opts conditionOptions, parent *condition) (*condition, error) {
+	cond := &condition{
+		frame:  opts.frame,
+		schema: s,
+		module: opts.module,
+		parent: parent,
+	}
+	if !opts.when {
+		cond.evaluated, cond.value = true, opts.value
+		if !opts.value {
+			tracer.Trace("Skip %s condition options", opts.frame)
+		}
+		return cond, nil
+	}
+	if opts.fn == nil {
+		return nil, &SignatureError{Kind: "condition", Frame: fmt.Sprint(opts.frame)}
+	}
+	fn, valid := inspectFunction(opts.fn)
+	if !valid || fn.NumOut() != 1 || fn.Out(0).Kind() != reflect.Bool {
+		return nil, &SignatureError{Kind: "condition", Type: reflect.TypeOf(opts.fn), Frame: fmt.Sprint(opts.frame)}
+	}
+	cond.fn = &fn
+	return cond, nil
+}
+
+// enabled evaluates condition and conditions of enclosing options, nil condition is always true. The chain
+// holds conditions which dependencies are being resolved, a condition that depends on its own options is
+// a cycle. The evaluation error is not kept, the condition is evaluated again on the next lookup.
+func (cond *condition) enabled(chain *evaluation) (bool, error) {
+	if cond == nil {
+		return true, nil
+	}
+	if ok, err := cond.parent.enabled(chain); !ok || err != nil {
+		return false, err
+	}
+	cond.mu.Lock()
+	evaluated, value := cond.evaluated, cond.value
+	cond.mu.Unlock()
+	if evaluated {
+		return value, nil
+	}
+	if chain.has(cond) {
+		return false, fmt.Errorf("condition at %s: %w", cond.frame, errCycleDetected)
+	}
+	s := scope{defaultSchema: cond.schema, module: cond.module, evaluation: &evaluation{cond: cond, prev: chain}}
+	args, err := arguments(s, *cond.fn)
+	if err != nil {
+		return false, fmt.Errorf("condition at %s: %w", cond.frame, err)
+	}
+	value = cond.fn.Call(args)[0].Bool()
+	cond.mu.Lock()
+	defer cond.mu.Unlock()
+	// the first result of concurrent evaluations is kept
+	if !cond.evaluated {
+		cond.evaluated, cond.value = true, value
+		if !value {
+			tracer.Trace("Skip %s condition options", cond.frame)
+		}
+	}
+	return cond.value, nil
+}
+
+// disabled returns true if condition or condition of enclosing options is evaluated to false. It doesn't
+// evaluate the condition.
+func (cond *condition) disabled() bool {
+	for ; cond != nil; cond = cond.parent {
+		cond.mu.Lock()
+		disabled := cond.evaluated && !cond.value
+		cond.mu.Unlock()
+		if disabled {
+			return true
+		}
+	}
+	return false
+}
+
+// enabled excludes nodes of conditional options which conditions are false.
+func enabled(nodes []*node, chain *evaluation) ([]*node, error) {
+	result := make([]*node, 0, len(nodes))
+	for _, n := range nodes {
+		ok, err := n.cond.enabled(chain)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", n, err)
+		}
+		if ok {
+			result = append(result, n)
+		}
+	}
+	return result, nil
+}
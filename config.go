@@ -0,0 +1,327 @@
+package di
+
+import (
+	"encoding"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigSource is a source of configuration values. See di.ProvideConfig().
+type ConfigSource interface {
+	load(target reflect.Value, fields []configField) error
+}
+
+// FromEnv returns configuration source that sets fields with env tag from environment variables.
+// The prefix is added to variable names:
+//
+//	type Config struct {
+//		Addr string `env:"ADDR"` // APP_ADDR
+//	}
+//
+//	di.ProvideConfig(&Config{}, di.FromEnv("APP_"))
+func FromEnv(prefix string) ConfigSource {
+	return configSource(func(target reflect.Value, fields []configField) error {
+		for _, field := range fields {
+			if field.env == "" {
+				continue
+			}
+			name := prefix + field.env
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				continue
+			}
+			if err := setConfigValue(field.value, value); err != nil {
+				return fmt.Errorf("%s: env %s: %w", field.path, name, err)
+			}
+			*field.set = true
+		}
+		return nil
+	})
+}
+
+// DecodeFunc decodes data into value pointed by v, e.g. yaml.Unmarshal or toml.Unmarshal.
+type DecodeFunc func(data []byte, v interface{}) error
+
+// FromFile returns configuration source that decodes JSON file into configuration. Field names are
+// specified with the json tags. Fields that are absent in the file keep their values. Use di.FromFileWith()
+// for other formats.
+func FromFile(path string) ConfigSource {
+	return configSource(func(target reflect.Value, fields []configField) error {
+		if ext := filepath.Ext(path); ext != ".json" {
+			return fmt.Errorf("%s: unsupported config file format %q, use di.FromFileWith() with its decoder", path, ext)
+		}
+		return decodeFile(path, json.Unmarshal, target, fields)
+	})
+}
+
+// FromFileWith returns configuration source that decodes file with the decoder. Fields that are absent
+// in the file keep their values:
+//
+//	di.ProvideConfig(&Config{}, di.FromFileWith("config.yaml", yaml.Unmarshal))
+func FromFileWith(path string, decode DecodeFunc) ConfigSource {
+	return configSource(func(target reflect.Value, fields []configField) error {
+		return decodeFile(path, decode, target, fields)
+	})
+}
+
+// decodeFile decodes file into configuration. The file is decoded into a copy of configuration with
+// changed values too, fields that are equal in both after decoding are set by the file.
+func decodeFile(path string, decode DecodeFunc, target reflect.Value, fields []configField) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	probe := reflect.New(target.Type())
+	probe.Elem().Set(target)
+	probeFields, _ := parseConfigFields(probe.Elem(), "", "")
+	changed := make([]bool, len(probeFields))
+	for i, field := range probeFields {
+		changed[i] = changeConfigValue(field.value)
+	}
+	if err := decode(data, target.Addr().Interface()); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := decode(data, probe.Interface()); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for i, field := range fields {
+		// value that can't be changed is set if it isn't zero
+		if changed[i] && reflect.DeepEqual(field.value.Interface(), probeFields[i].value.Interface()) ||
+			!changed[i] && !field.value.IsZero() {
+			*field.set = true
+		}
+	}
+	return nil
+}
+
+// changeConfigValue replaces value with another one, it returns false if value can't be changed.
+func changeConfigValue(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(rv.String() + "\x00")
+	case reflect.Bool:
+		rv.SetBool(!rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		rv.SetInt(rv.Int() ^ 1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		rv.SetUint(rv.Uint() ^ 1)
+	case reflect.Float32, reflect.Float64:
+		if rv.Float() == 0 {
+			rv.SetFloat(1)
+		} else {
+			rv.SetFloat(-rv.Float())
+		}
+	case reflect.Slice:
+		slice := reflect.MakeSlice(rv.Type(), rv.Len()+1, rv.Len()+1)
+		reflect.Copy(slice, rv)
+		rv.Set(slice)
+	default:
+		return false
+	}
+	return true
+}
+
+// FromFlags returns configuration source that sets fields with flag tag from flags of the set. The
+// flags must be defined and parsed before, only explicitly set flags are used:
+//
+//	type Config struct {
+//		Addr string `flag:"addr"`
+//	}
+//
+//	flag.String("addr", "", "server address")
+//	flag.Parse()
+//	di.ProvideConfig(&Config{}, di.FromFlags(flag.CommandLine))
+func FromFlags(set *flag.FlagSet) ConfigSource {
+	return configSource(func(target reflect.Value, fields []configField) error {
+		if set == nil {
+			return fmt.Errorf("flag set is nil")
+		}
+		values := map[string]string{}
+		set.Visit(func(f *flag.Flag) {
+			values[f.Name] = f.Value.String()
+		})
+		for _, field := range fields {
+			value, ok := values[field.flag]
+			if field.flag == "" || !ok {
+				continue
+			}
+			if err := setConfigValue(field.value, value); err != nil {
+				return fmt.Errorf("%s: flag %s: %w", field.path, field.flag, err)
+			}
+			*field.set = true
+		}
+		return nil
+	})
+}
+
+type configSource func(target reflect.Value, fields []configField) error
+
+func (s configSource) load(target reflect.Value, fields []configField) error {
+	return s(target, fields)
+}
+
+// configField is a field of configuration that is set from sources. The set field is true if value is
+// set by the configuration itself, default tag or any source.
+type configField struct {
+	path     string
+	value    reflect.Value
+	env      string
+	flag     string
+	def      string
+	required bool
+	set      *bool
+}
+
+// struct that contains configuration with its sources.
+type configOptions struct {
+	frame   callerFrame
+	target  Pointer
+	sources []ConfigSource
+	module  *module
+}
+
+// loadConfig fills configuration from default values and sources, and checks required fields. It returns
+// pointers to configuration and its sub-structures that are provided as separate types.
+func loadConfig(target Pointer, sources []ConfigSource) (values []reflect.Value, err error) {
+	rv := reflect.ValueOf(target)
+	if target == nil || rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct || rv.IsNil() {
+		return nil, fmt.Errorf("config must be a pointer to struct, got %s", reflect.TypeOf(target))
+	}
+	fields, provided := parseConfigFields(rv.Elem(), rv.Type().String(), "")
+	for _, field := range fields {
+		*field.set = !field.value.IsZero()
+		if field.def == "" {
+			continue
+		}
+		if err := setConfigValue(field.value, field.def); err != nil {
+			return nil, fmt.Errorf("%s: default: %w", field.path, err)
+		}
+		*field.set = true
+	}
+	for _, source := range sources {
+		if source == nil {
+			return nil, fmt.Errorf("invalid config source, got nil")
+		}
+		if err := source.load(rv.Elem(), fields); err != nil {
+			return nil, err
+		}
+	}
+	for _, field := range fields {
+		if field.required && !*field.set {
+			return nil, fmt.Errorf("%s: required field is not set", field.path)
+		}
+	}
+	values = append(values, rv)
+	// sub-structures are provided as pointers into configuration like configuration itself
+	for _, field := range provided {
+		values = append(values, field.value.Addr())
+	}
+	return values, nil
+}
+
+// parseConfigFields parses exported fields of configuration. Nested structures are parsed recursively,
+// env tag of nested structure is a prefix of its variables. Fields with tag provide:"true" are returned
+// separately.
+func parseConfigFields(rv reflect.Value, path string, env string) (fields []configField, provided []configField) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		field := configField{
+			path:     path + "." + sf.Name,
+			value:    rv.Field(i),
+			flag:     sf.Tag.Get("flag"),
+			def:      sf.Tag.Get("default"),
+			required: sf.Tag.Get("required") == "true",
+			set:      new(bool),
+		}
+		if name, ok := sf.Tag.Lookup("env"); ok {
+			field.env = env + name
+		}
+		if sf.Tag.Get("provide") == "true" {
+			provided = append(provided, field)
+		}
+		if sf.Type.Kind() == reflect.Struct && !isTextUnmarshaler(sf.Type) {
+			nested, nestedProvided := parseConfigFields(field.value, field.path, field.env)
+			fields = append(fields, nested...)
+			provided = append(provided, nestedProvided...)
+			continue
+		}
+		fields = append(fields, field)
+	}
+	return fields, provided
+}
+
+var (
+	textUnmarshalerInterface = reflect.TypeOf(new(encoding.TextUnmarshaler)).Elem()
+	durationType             = reflect.TypeOf(time.Duration(0))
+)
+
+// isTextUnmarshaler checks that pointer to typ implements encoding.TextUnmarshaler.
+func isTextUnmarshaler(typ reflect.Type) bool {
+	return reflect.PtrTo(typ).Implements(textUnmarshalerInterface)
+}
+
+// setConfigValue parses string into value.
+func setConfigValue(rv reflect.Value, s string) error {
+	if isTextUnmarshaler(rv.Type()) {
+		return rv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	if rv.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		rv.SetInt(int64(d))
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		rv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetFloat(f)
+	case reflect.Slice:
+		parts := strings.Split(s, ",")
+		slice := reflect.MakeSlice(rv.Type(), len(parts), len(parts))
+		for i, part := range parts {
+			if err := setConfigValue(slice.Index(i), strings.TrimSpace(part)); err != nil {
+				return err
+			}
+		}
+		rv.Set(slice)
+	default:
+		return fmt.Errorf("unsupported type %s", rv.Type())
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package di
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
@@ -10,8 +11,6 @@ import (
 type Container struct {
 	// Dependency injection schema.
 	schema *defaultSchema
-	// Array of provider cleanups.
-	cleanups []func()
 }
 
 // New constructs container with provided options. Example usage (simplified):
@@ -44,8 +43,7 @@ type Container struct {
 //	}
 func New(options ...Option) (_ *Container, err error) {
 	c := &Container{
-		schema:   newDefaultSchema(),
-		cleanups: []func(){},
+		schema: newDefaultSchema(),
 	}
 	var di diopts
 	// apply container diopts
@@ -53,7 +51,7 @@ func New(options ...Option) (_ *Container, err error) {
 		opt.apply(&di)
 	}
 	// provide container to advanced usage e.g. condition providing
-	_ = c.provide(func() *Container { return c })
+	c.provideSelf(stacktrace(0), di.resolverOnly)
 	if err := c.apply(di); err != nil {
 		return nil, err
 	}
@@ -69,10 +67,16 @@ func New(options ...Option) (_ *Container, err error) {
 //		// handle error
 //	}
 func (c *Container) Apply(options ...Option) error {
+	if err := c.checkSealed("apply"); err != nil {
+		return errWithStack(err)
+	}
 	var di diopts
 	for _, opt := range options {
 		opt.apply(&di)
 	}
+	if di.resolverOnly {
+		return errWithStack(fmt.Errorf("di.ResolverOnly() can be used only in di.New()"))
+	}
 	return c.apply(di)
 }
 
@@ -80,7 +84,10 @@ func (c *Container) Apply(options ...Option) error {
 // For more information about constructors see Constructor interface. ProvideOption can add additional behavior to
 // the process of type resolving.
 func (c *Container) Provide(constructor Constructor, options ...ProvideOption) error {
-	if err := c.provide(constructor, options...); err != nil {
+	if err := c.checkSealed("provide"); err != nil {
+		return errWithStack(err)
+	}
+	if err := c.provide(stacktrace(0), nil, constructor, options...); err != nil {
 		return errWithStack(err)
 	}
 	return nil
@@ -88,7 +95,83 @@ func (c *Container) Provide(constructor Constructor, options ...ProvideOption) e
 
 // ProvideValue provides value as is.
 func (c *Container) ProvideValue(value Value, options ...ProvideOption) error {
-	if err := c.provideValue(value, options...); err != nil {
+	if err := c.checkSealed("provide"); err != nil {
+		return errWithStack(err)
+	}
+	if err := c.provideValue(stacktrace(0), nil, value, options...); err != nil {
+		return errWithStack(err)
+	}
+	return nil
+}
+
+// Decorate registers value-replacing decorator. The decorator is applied to every type of its first argument
+// in the container and its child containers after type construction. See Wrapper for details.
+//
+//	err := container.Decorate(func(orig Repository, cache *Cache) (Repository, error) {
+//		return &CachedRepository{Repository: orig, cache: cache}, nil
+//	})
+//	if err != nil {
+//		// handle error
+//	}
+//
+// Decorators are applied in the order of registration. Use ResolveOption to decorate only types with
+// specific tags. Decorator must be registered before its type will be resolved.
+func (c *Container) Decorate(wrapper Wrapper, options ...ResolveOption) error {
+	if err := c.checkSealed("decorate"); err != nil {
+		return errWithStack(err)
+	}
+	if err := c.decorate(stacktrace(0), nil, wrapper, options...); err != nil {
+		return errWithStack(err)
+	}
+	return nil
+}
+
+// DecorateAll registers container-wide decorator. The decorator is applied to every type selected by
+// the matcher in the container and its child containers, including types that will be provided later.
+// The matcher is a di.OfType(), di.Implements() or di.Tags:
+//
+//	// wrap every http.Handler with logging
+//	err := container.DecorateAll(di.Implements(new(http.Handler)), func(h http.Handler, logger *log.Logger) http.Handler {
+//		return NewLoggingHandler(h, logger)
+//	})
+//
+// The decorator is applied only to types that are assignable to its first argument, and to which its result is
+// assignable. The decorator that returns only error checks values without replacing them:
+//
+//	err := container.DecorateAll(di.Implements(new(Validatable)), func(config Validatable) error {
+//		return config.Validate()
+//	})
+//
+// See Wrapper for details.
+func (c *Container) DecorateAll(matcher Matcher, wrapper Wrapper) error {
+	if err := c.checkSealed("decorate"); err != nil {
+		return errWithStack(err)
+	}
+	if err := c.decorateAll(stacktrace(0), nil, matcher, wrapper); err != nil {
+		return errWithStack(err)
+	}
+	return nil
+}
+
+// Intercept registers interceptors of interface methods. The container returns a proxy of the interface
+// provided with di.As() that routes every method call through the interceptor chain:
+//
+//	err := container.Intercept(new(Repository), func(call di.Call) []reflect.Value {
+//		log.Printf("call %s", call.Method)
+//		return call.Proceed()
+//	})
+//
+// Go cannot implement interfaces at runtime, the proxy must be generated with the di-proxy tool:
+//
+//	//go:generate go run github.com/defval/di/cmd/di-proxy -type Repository
+//
+// Interceptors are called in the order of registration. Interceptors are registered as decorators,
+// they must be registered before the interface will be resolved. See Interceptor for details.
+func (c *Container) Intercept(i Interface, interceptors ...Interceptor) error {
+	if err := c.checkSealed("intercept"); err != nil {
+		return errWithStack(err)
+	}
+	if err := c.intercept(stacktrace(0), nil, i, interceptors...); err != nil {
 		return errWithStack(err)
 	}
 	return nil
@@ -108,7 +191,27 @@ type Invocation interface{}
 // Invoke calls the function fn. It parses function parameters. Looks for it in a container.
 // And invokes function with them. See Invocation for details.
 func (c *Container) Invoke(invocation Invocation, options ...InvokeOption) error {
-	err := c.invoke(invocation, options...)
+	err := c.invoke(c.schema, stacktrace(0), invocation, options...)
+	if err != nil && knownError(err) {
+		return errWithStack(err)
+	}
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// InvokeContext calls the function like Invoke() with context of resolution. Constructors and the function
+// with context.Context parameter receive ctx. The resolution is aborted with ctx.Err() when ctx is done
+// between constructor calls:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+//	defer cancel()
+//	if err := container.InvokeContext(ctx, StartServer); err != nil {
+//		// handle error
+//	}
+func (c *Container) InvokeContext(ctx context.Context, invocation Invocation, options ...InvokeOption) error {
+	err := c.invoke(withContext(c.schema, ctx), stacktrace(0), invocation, options...)
 	if err != nil && knownError(err) {
 		return errWithStack(err)
 	}
@@ -129,10 +232,12 @@ type Pointer interface{}
 //
 // It like Resolve() but doesn't instantiate a type.
 func (c *Container) Has(target Pointer, options ...ResolveOption) (bool, error) {
-	if _, err := c.find(target, options...); errors.Is(err, ErrTypeNotExists) {
+	// the type itself is missing if the error has no dependency path
+	var missing *MissingError
+	if _, err := c.find(c.schema, target, options...); errors.As(err, &missing) && len(missing.Path) == 0 {
 		return false, nil
 	} else if err != nil {
-		return false, err
+		return false, reported(err)
 	}
 	return true, nil
 }
@@ -144,7 +249,27 @@ func (c *Container) Has(target Pointer, options ...ResolveOption) (bool, error)
 //		// handle error
 //	}
 func (c *Container) Resolve(ptr Pointer, options ...ResolveOption) error {
-	if err := c.resolve(ptr, options...); err != nil {
+	if err := c.resolve(c.schema, ptr, options...); err != nil {
+		return errWithStack(err)
+	}
+	return nil
+}
+
+// ResolveContext resolves type like Resolve() with context of resolution. Constructors with context.Context
+// parameter receive ctx, for example, to dial with the startup deadline:
+//
+//	func NewDB(ctx context.Context, config *Config) (*sql.DB, error) {
+//		db, err := sql.Open("postgres", config.DSN)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return db, db.PingContext(ctx)
+//	}
+//
+// The resolution is aborted with ctx.Err() when ctx is done between constructor calls. Without context
+// of resolution constructors receive context.Background(), unless context.Context is provided.
+func (c *Container) ResolveContext(ctx context.Context, ptr Pointer, options ...ResolveOption) error {
+	if err := c.resolve(withContext(c.schema, ctx), ptr, options...); err != nil {
 		return errWithStack(err)
 	}
 	return nil
@@ -169,13 +294,33 @@ type IterateFunc func(tags Tags, value ValueFunc) error
 //	 }
 //	 container.Iterate(&servers, iterFn)
 func (c *Container) Iterate(target Pointer, fn IterateFunc, options ...ResolveOption) error {
-	node, err := c.find(target, options...)
+	node, err := c.find(c.schema, target, options...)
 	if err != nil {
-		return err
+		return reported(err)
 	}
 	group, ok := node.compiler.(*groupCompiler)
 	if ok {
-		for i, n := range group.matched {
+		i := 0
+		for _, n := range group.matched {
+			if n.flatten {
+				// flattened node must be built to know its members
+				v, err := n.Value(c.schema)
+				if err != nil {
+					return fmt.Errorf("%s with index %d failed: %s", node, i, err)
+				}
+				for _, elem := range n.elements(v) {
+					elem := elem
+					err = fn(n.tags, func() (interface{}, error) {
+						return elem.Interface(), nil
+					})
+					if err != nil {
+						return fmt.Errorf("%s with index %d failed: %s", node, i, err)
+					}
+					i++
+				}
+				continue
+			}
+			n := n
 			err = fn(n.tags, func() (interface{}, error) {
 				v, err := n.Value(c.schema)
 				if err != nil {
@@ -186,6 +331,7 @@ func (c *Container) Iterate(target Pointer, fn IterateFunc, options ...ResolveOp
 			if err != nil {
 				return fmt.Errorf("%s with index %d failed: %s", node, i, err)
 			}
+			i++
 		}
 		return nil
 	}
@@ -194,35 +340,108 @@ func (c *Container) Iterate(target Pointer, fn IterateFunc, options ...ResolveOp
 
 // Cleanup runs destructors in reverse order that was been created.
 func (c *Container) Cleanup() {
-	for i := len(c.schema.cleanups) - 1; i >= 0; i-- {
-		c.schema.cleanups[i]()
+	g := c.schema.generation.current()
+	g.RLock()
+	cleanups := c.schema.cleanups
+	g.RUnlock()
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i].fn()
+	}
+}
+
+// Invalidate drops the resolved value of the type and values of all types that transitively depend on it.
+// Cleanups of dropped values are run in reverse order. The next resolve builds them again with fresh
+// dependencies:
+//
+//	if err := container.Invalidate(&config); err != nil {
+//		// handle error
+//	}
+//	// server is rebuilt with new config
+//	if err := container.Resolve(&server); err != nil {
+//		// handle error
+//	}
+//
+// Values of child containers are not dropped.
+func (c *Container) Invalidate(ptr Pointer, options ...ResolveOption) error {
+	node, err := c.find(c.schema, ptr, options...)
+	if err != nil {
+		return errWithStack(err)
+	}
+	c.schema.invalidate(node)
+	return nil
+}
+
+// Unused returns providers of the container which types were never requested by invocations, resolves
+// or other types, with their locations. Use it to find dead wiring:
+//
+//	for _, provider := range container.Unused() {
+//		log.Printf("unused provider: %s", provider)
+//	}
+func (c *Container) Unused() []string {
+	var unused []*node
+	for _, n := range c.schema.registered {
+		// the container itself is provided implicitly
+		if n.used || n.origin != nil || n.self {
+			continue
+		}
+		unused = append(unused, n)
 	}
+	return sources(unused)
+}
+
+// Reload rebuilds the reloadable type and all types that transitively depend on it, see di.Reloadable().
+// New values replace old ones only if every constructor succeeds, otherwise old values are kept and the
+// error is returned. Cleanups of old values run after the swap in reverse order. di.Live handles get new
+// values atomically:
+//
+//	watcher.OnChange(func() {
+//		if err := container.Reload(new(*Config)); err != nil {
+//			// handle error, old config is still used
+//		}
+//	})
+//
+// New values are built off to the side and published at once, so types resolved concurrently with Reload
+// get old values until then. Concurrent Reload calls run one after another.
+//
+// Child containers don't get new values, so Reload fails without changes if a value to rebuild was resolved
+// in a child container. Recreate child containers to use the new values.
+func (c *Container) Reload(ptr Pointer, options ...ResolveOption) error {
+	node, err := c.find(c.schema, ptr, options...)
+	if err != nil {
+		return errWithStack(err)
+	}
+	if err := c.schema.reload(node); err != nil {
+		return errWithStack(err)
+	}
+	return nil
 }
 
 // AddParent adds a parent container. Types are resolved from the container,
 // it's parents, and ancestors. An error is a cycle is detected in ancestry tree.
 func (c *Container) AddParent(parent *Container) error {
+	if err := c.checkSealed("add parent"); err != nil {
+		return errWithStack(err)
+	}
 	return c.schema.addParent(parent.schema)
 }
 
 func (c *Container) apply(di diopts) error {
-	for _, provide := range di.values {
-		if err := c.provideValue(provide.value, provide.options...); err != nil {
-			return fmt.Errorf("%s: %w", provide.frame, err)
-		}
-	}
-	// process di.Resolve() diopts
-	for _, provide := range di.provides {
-		if err := c.provide(provide.constructor, provide.options...); err != nil {
-			return fmt.Errorf("%s: %w", provide.frame, err)
-		}
+	if err := c.register(&di); err != nil {
+		return err
 	}
 	// error omitted because if logger could not be resolved it will be default
 	// process di.Invoke() diopts
 	for _, invoke := range di.invokes {
-		err := c.invoke(invoke.fn, invoke.options...)
+		ok, err := invoke.cond.enabled(nil)
+		if err != nil {
+			return errWithFrame(invoke.frame, err)
+		}
+		if !ok {
+			continue
+		}
+		err = c.invoke(inModule(c.schema, invoke.module), invoke.frame, invoke.fn, invoke.options...)
 		if err != nil && knownError(err) {
-			return fmt.Errorf("%s: %w", invoke.frame, err)
+			return errWithFrame(invoke.frame, err)
 		}
 		if err != nil {
 			return err
@@ -230,16 +449,140 @@ func (c *Container) apply(di diopts) error {
 	}
 	// process di.Resolve() diopts
 	for _, resolve := range di.resolves {
-		if err := c.resolve(resolve.target, resolve.options...); err != nil {
-			return fmt.Errorf("%s: %w", resolve.frame, err)
+		ok, err := resolve.cond.enabled(nil)
+		if err != nil {
+			return errWithFrame(resolve.frame, err)
+		}
+		if !ok {
+			continue
 		}
+		if err := c.resolve(inModule(c.schema, resolve.module), resolve.target, resolve.options...); err != nil {
+			return errWithFrame(resolve.frame, err)
+		}
+	}
+	// process di.Sealed() option
+	if di.sealed != nil {
+		c.schema.seal(*di.sealed)
 	}
 	return nil
 }
 
-func (c *Container) provide(constructor Constructor, options ...ProvideOption) error {
+// register registers types, decorators and modules, then evaluates conditions. Invocations and resolves
+// of applied conditional options are appended to di.
+func (c *Container) register(di *diopts) error {
+	for _, setting := range di.settings {
+		setting(c)
+	}
+	for _, m := range di.modules {
+		if err := c.schema.addModule(m); err != nil {
+			return errWithFrame(m.frame, err)
+		}
+	}
+	// required modules are checked before any type is provided
+	for _, imp := range di.imports {
+		for _, name := range imp.modules {
+			if _, ok := c.schema.module(name); ok {
+				continue
+			}
+			if imp.module != nil {
+				return fmt.Errorf("%s: %s requires module %s that is not registered", imp.frame, imp.module, name)
+			}
+			return fmt.Errorf("%s: container requires module %s that is not registered", imp.frame, name)
+		}
+	}
+	for _, config := range di.configs {
+		err := c.provideConfig(config.frame, config.module, config.target, config.sources...)
+		// configuration of disabled conditional options may be incomplete
+		if err != nil && c.schema.condition != nil {
+			if ok, cerr := c.schema.condition.enabled(nil); cerr == nil && !ok {
+				continue
+			}
+		}
+		if err != nil {
+			return errWithFrame(config.frame, err)
+		}
+	}
+	for _, provide := range di.values {
+		if err := c.provideValue(provide.frame, provide.module, provide.value, provide.options...); err != nil {
+			return errWithFrame(provide.frame, err)
+		}
+	}
+	// process di.Resolve() diopts
+	for _, provide := range di.provides {
+		if err := c.provide(provide.frame, provide.module, provide.constructor, provide.options...); err != nil {
+			return errWithFrame(provide.frame, err)
+		}
+	}
+	for _, wrap := range di.wraps {
+		var err error
+		switch {
+		case wrap.intercept:
+			err = c.intercept(wrap.frame, wrap.module, wrap.iface, wrap.interceptors...)
+		case wrap.matcher != nil:
+			err = c.decorateAll(wrap.frame, wrap.module, wrap.matcher, wrap.wrapper)
+		default:
+			err = c.decorate(wrap.frame, wrap.module, wrap.wrapper, wrap.options...)
+		}
+		if err != nil {
+			return errWithFrame(wrap.frame, err)
+		}
+	}
+	// types and decorators of conditional options are registered after unconditional ones, their conditions
+	// are evaluated on the first lookup
+	for _, opts := range di.conditions {
+		cond, err := newCondition(c.schema, opts, c.schema.condition)
+		if err != nil {
+			return errWithFrame(opts.frame, err)
+		}
+		inner := diopts{}
+		for _, opt := range opts.options {
+			opt.apply(&inner)
+		}
+		inner.inModule(opts.module)
+		// the container is provided before conditions are evaluated
+		if inner.resolverOnly {
+			return fmt.Errorf("%s: di.ResolverOnly() can't be applied conditionally", opts.frame)
+		}
+		// settings change the container at once, so the condition is evaluated now
+		if len(inner.settings) > 0 || inner.sealed != nil {
+			ok, err := cond.enabled(nil)
+			if err != nil {
+				return errWithFrame(opts.frame, err)
+			}
+			if !ok {
+				inner.settings, inner.sealed = nil, nil
+			}
+		}
+		parent := c.schema.condition
+		c.schema.condition = cond
+		err = c.register(&inner)
+		c.schema.condition = parent
+		if err != nil {
+			return err
+		}
+		// invocations and resolves of nested conditional options keep their conditions
+		for i := range inner.invokes {
+			if inner.invokes[i].cond == nil {
+				inner.invokes[i].cond = cond
+			}
+		}
+		for i := range inner.resolves {
+			if inner.resolves[i].cond == nil {
+				inner.resolves[i].cond = cond
+			}
+		}
+		di.invokes = append(di.invokes, inner.invokes...)
+		if inner.sealed != nil {
+			di.sealed = inner.sealed
+		}
+		di.resolves = append(di.resolves, inner.resolves...)
+	}
+	return nil
+}
+
+func (c *Container) provide(frame callerFrame, m *module, constructor Constructor, options ...ProvideOption) error {
 	if constructor == nil {
-		return fmt.Errorf("invalid constructor signature, got nil")
+		return &SignatureError{Kind: "constructor", Frame: fmt.Sprint(frame)}
 	}
 	params := ProvideParams{}
 	// apply provide options
@@ -248,16 +591,21 @@ func (c *Container) provide(constructor Constructor, options ...ProvideOption) e
 	}
 	n, err := newConstructorNode(constructor)
 	if err != nil {
-		return err
+		return signatureAt(frame, err)
 	}
+	n.frame = frame
+	n.module = m
 	n.decorators = params.Decorators
+	cmp := n.compiler.(*constructorCompiler)
+	cmp.timeout = params.Timeout
+	cmp.retry = params.Retry
 	for k, v := range params.Tags {
 		n.tags[k] = v
 	}
 	return c.provideNode(n, params)
 }
 
-func (c *Container) provideValue(value Value, options ...ProvideOption) error {
+func (c *Container) provideValue(frame callerFrame, m *module, value Value, options ...ProvideOption) error {
 	if value == nil {
 		return fmt.Errorf("invalid value, got nil")
 	}
@@ -274,12 +622,47 @@ func (c *Container) provideValue(value Value, options ...ProvideOption) error {
 		rv:         new(reflect.Value),
 		rt:         v.Type(),
 		tags:       params.Tags,
+		frame:      frame,
+		module:     m,
 		decorators: params.Decorators,
 	}
 	return c.provideNode(n, params)
 }
 
+func (c *Container) provideConfig(frame callerFrame, m *module, target Pointer, sources ...ConfigSource) error {
+	values, err := loadConfig(target, sources)
+	if err != nil {
+		return err
+	}
+	for _, v := range values {
+		if err := c.provideValue(frame, m, v.Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *Container) provideNode(n *node, params ProvideParams) error {
+	if params.Flatten {
+		if n.rt.Kind() != reflect.Slice {
+			return fmt.Errorf("%s: only slices can be flattened", n)
+		}
+		n.rt = n.rt.Elem()
+		n.flatten = true
+	}
+	if params.Private && n.module == nil {
+		return fmt.Errorf("%s: only types provided inside module can be private", n)
+	}
+	n.private = params.Private
+	n.reloadable = params.Reloadable
+	n.primary = params.Primary
+	n.groups = params.Groups
+	n.order = params.Order
+	n.before = params.Before
+	n.after = params.After
+	if prev, ok := c.schema.duplicate(n); ok && c.schema.strict {
+		return fmt.Errorf("%s: %w, already provided at %s", n, errMultipleDefinitions, prev.frame)
+	}
 	c.schema.register(n)
 	// register interfaces
 	for _, cur := range params.Interfaces {
@@ -290,23 +673,82 @@ func (c *Container) provideNode(n *node, params ProvideParams) error {
 		if !n.rt.Implements(i.Type) {
 			return fmt.Errorf("%s not implement %s", n, i.Type)
 		}
-		c.schema.register(&node{
-			rv:         n.rv,
-			rt:         i.Type,
-			tags:       n.tags,
-			compiler:   n.compiler,
-			decorators: n.decorators,
-		})
+		// interface node shares value and options with provided node
+		in := *n
+		in.rt = i.Type
+		in.origin = n
+		c.schema.register(&in)
 	}
 	return nil
 }
 
-func (c *Container) resolve(ptr Pointer, options ...ResolveOption) error {
-	node, err := c.find(ptr, options...)
+func (c *Container) decorate(frame callerFrame, m *module, wrapper Wrapper, options ...ResolveOption) error {
+	params := ResolveParams{}
+	for _, opt := range options {
+		opt.applyResolve(&params)
+	}
+	w, err := newWrapper(wrapper, func(rt reflect.Type, tags Tags) bool {
+		return tags.match(params.Tags)
+	})
+	if err != nil {
+		return signatureAt(frame, err)
+	}
+	// decorate exact type of the first argument
+	typ := w.fn.In(0)
+	selector := w.selector
+	w.selector = func(rt reflect.Type, tags Tags) bool {
+		return rt == typ && selector(rt, tags)
+	}
+	w.frame = frame
+	w.module = m
+	c.schema.decorate(w)
+	return nil
+}
+
+func (c *Container) decorateAll(frame callerFrame, m *module, matcher Matcher, wrapper Wrapper) error {
+	if matcher == nil {
+		return fmt.Errorf("invalid matcher, got nil")
+	}
+	selector, err := matcher.selector()
 	if err != nil {
 		return err
 	}
-	value, err := node.Value(c.schema)
+	w, err := newWrapper(wrapper, selector)
+	if err != nil {
+		return signatureAt(frame, err)
+	}
+	w.frame = frame
+	w.module = m
+	c.schema.decorate(w)
+	return nil
+}
+
+func (c *Container) intercept(frame callerFrame, m *module, i Interface, interceptors ...Interceptor) error {
+	link, err := inspectInterfacePointer(i)
+	if err != nil {
+		return err
+	}
+	for _, interceptor := range interceptors {
+		if interceptor == nil {
+			return fmt.Errorf("invalid interceptor, got nil")
+		}
+	}
+	w, err := newInterceptWrapper(link.Type, interceptors)
+	if err != nil {
+		return err
+	}
+	w.frame = frame
+	w.module = m
+	c.schema.decorate(w)
+	return nil
+}
+
+func (c *Container) resolve(s schema, ptr Pointer, options ...ResolveOption) error {
+	node, err := c.find(s, ptr, options...)
+	if err != nil {
+		return err
+	}
+	value, err := node.Value(s)
 	if err != nil {
 		return fmt.Errorf("%s: %w", node, err)
 	}
@@ -322,44 +764,52 @@ func (c *Container) resolve(ptr Pointer, options ...ResolveOption) error {
 	return nil
 }
 
-func (c *Container) invoke(invocation Invocation, _ ...InvokeOption) error {
+func (c *Container) invoke(s schema, frame callerFrame, invocation Invocation, _ ...InvokeOption) error {
 	// params := InvokeParams{}
 	// for _, opt := range diopts {
 	// 	opt.apply(&params)
 	// }
 	if invocation == nil {
-		return fmt.Errorf("%w, got %s", errInvalidInvocationSignature, "nil")
+		return &SignatureError{Kind: "invocation", Frame: fmt.Sprint(frame)}
 	}
 	fn, valid := inspectFunction(invocation)
-	if !valid {
-		return fmt.Errorf("%w, got %s", errInvalidInvocationSignature, reflect.TypeOf(invocation))
-	}
-	if !validateInvocation(fn) {
-		return fmt.Errorf("%w, got %s", errInvalidInvocationSignature, reflect.TypeOf(invocation))
+	if !valid || !validateInvocation(fn) {
+		return &SignatureError{Kind: "invocation", Type: reflect.TypeOf(invocation), Frame: fmt.Sprint(frame)}
 	}
-	nodes, err := parseInvocationParameters(fn, c.schema)
+	args, err := arguments(s, fn)
 	if err != nil {
 		return err
 	}
+	return protect(s, fn.Name, func() error {
+		res := funcResult(fn.Call(args))
+		if len(res) == 0 {
+			return nil
+		}
+		return res.error(0)
+	})
+}
+
+// arguments resolves arguments of function from the scope.
+func arguments(s schema, fn function) ([]reflect.Value, error) {
+	nodes, err := parseInvocationParameters(fn, s)
+	if err != nil {
+		return nil, err
+	}
 	var args []reflect.Value
 	for _, node := range nodes {
-		if err := c.schema.prepare(node); err != nil {
-			return err
+		if err := prepare(s, node); err != nil {
+			return nil, err
 		}
-		v, err := node.Value(c.schema)
+		v, err := node.Value(s)
 		if err != nil {
-			return fmt.Errorf("%s: %s", node, err)
+			return nil, fmt.Errorf("%s: %w", node, err)
 		}
 		args = append(args, v)
 	}
-	res := funcResult(fn.Call(args))
-	if len(res) == 0 {
-		return nil
-	}
-	return res.error(0)
+	return args, nil
 }
 
-func (c *Container) find(ptr Pointer, options ...ResolveOption) (*node, error) {
+func (c *Container) find(s schema, ptr Pointer, options ...ResolveOption) (*node, error) {
 	if ptr == nil {
 		return nil, fmt.Errorf("target must be a pointer, got nil")
 	}
@@ -371,23 +821,109 @@ func (c *Container) find(ptr Pointer, options ...ResolveOption) (*node, error) {
 	for _, opt := range options {
 		opt.applyResolve(&params)
 	}
-	node, err := c.schema.find(reflect.TypeOf(ptr).Elem(), params.Tags)
+	node, err := s.find(reflect.TypeOf(ptr).Elem(), params.Tags)
 	if err != nil {
 		return nil, err
 	}
-	if err := c.schema.prepare(node); err != nil {
+	// dependency graph of sealed container doesn't change
+	base, _ := unscope(s)
+	if _, ok := base.prepared.Load(node); ok {
+		return node, nil
+	}
+	if err := prepare(s, node); err != nil {
 		return nil, err
 	}
+	if base.frozen() && node.owner != nil {
+		base.prepared.Store(node, true)
+	}
 	return node, nil
 }
 
 type diopts struct {
+	// Array of container settings.
+	settings []func(c *Container)
 	// Array of di.Provide() options.
 	provides []provideOptions
 	// Array of di.ProvideValue() options.
 	values []provideValueOptions
+	// Array of di.ProvideConfig() options.
+	configs []configOptions
+	// Array of di.Wrap() options.
+	wraps []wrapOptions
 	// Array of di.Invoke() options.
 	invokes []invokeOptions
 	// Array of di.Resolve() options.
 	resolves []resolveOptions
+	// Array of di.Module() modules.
+	modules []*module
+	// Array of di.Import() options.
+	imports []importOptions
+	// Array of di.If(), di.IfEnv() and di.When() options.
+	conditions []conditionOptions
+	// Location of di.Sealed() option.
+	sealed *callerFrame
+	// resolverOnly provides container as di.Resolver, see di.ResolverOnly().
+	resolverOnly bool
+}
+
+// inModule sets module of options that don't belong to nested modules.
+func (o *diopts) inModule(m *module) {
+	for i := range o.provides {
+		if o.provides[i].module == nil {
+			o.provides[i].module = m
+		}
+	}
+	for i := range o.values {
+		if o.values[i].module == nil {
+			o.values[i].module = m
+		}
+	}
+	for i := range o.configs {
+		if o.configs[i].module == nil {
+			o.configs[i].module = m
+		}
+	}
+	for i := range o.wraps {
+		if o.wraps[i].module == nil {
+			o.wraps[i].module = m
+		}
+	}
+	for i := range o.invokes {
+		if o.invokes[i].module == nil {
+			o.invokes[i].module = m
+		}
+	}
+	for i := range o.resolves {
+		if o.resolves[i].module == nil {
+			o.resolves[i].module = m
+		}
+	}
+	for i := range o.imports {
+		if o.imports[i].module == nil {
+			o.imports[i].module = m
+		}
+	}
+	for i := range o.conditions {
+		if o.conditions[i].module == nil {
+			o.conditions[i].module = m
+		}
+	}
+}
+
+// merge appends options of other.
+func (o *diopts) merge(other diopts) {
+	o.settings = append(o.settings, other.settings...)
+	o.provides = append(o.provides, other.provides...)
+	o.values = append(o.values, other.values...)
+	o.configs = append(o.configs, other.configs...)
+	o.wraps = append(o.wraps, other.wraps...)
+	o.invokes = append(o.invokes, other.invokes...)
+	o.resolves = append(o.resolves, other.resolves...)
+	o.modules = append(o.modules, other.modules...)
+	o.imports = append(o.imports, other.imports...)
+	o.conditions = append(o.conditions, other.conditions...)
+	if other.sealed != nil {
+		o.sealed = other.sealed
+	}
+	o.resolverOnly = o.resolverOnly || other.resolverOnly
 }
@@ -1,13 +1,20 @@
 package di_test
 
 import (
+	"context"
+	"encoding/xml"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -1556,79 +1563,2468 @@ func TestContainer_Cleanup(t *testing.T) {
 	})
 }
 
+func TestContainer_Errors(t *testing.T) {
+	t.Run("missing dependency", func(t *testing.T) {
+		c, err := di.New(
+			di.Provide(func(addr string) *http.Server { return &http.Server{Addr: addr} }),
+			di.Provide(func(server *http.Server) *http.Client { return &http.Client{} }),
+		)
+		require.NoError(t, err)
+		var client *http.Client
+		err = c.Resolve(&client)
+		require.Contains(t, err.Error(), ": *http.Server: type string not exists in the container")
+		require.True(t, errors.Is(err, di.ErrTypeNotExists))
+		var missing *di.MissingError
+		require.True(t, errors.As(err, &missing))
+		require.Equal(t, reflect.TypeOf(""), missing.Type)
+		require.Equal(t, []string{"*http.Client", "*http.Server"}, missing.Path)
+		require.Contains(t, missing.Frame, "container_test.go:")
+	})
+
+	t.Run("suggestions", func(t *testing.T) {
+		c, err := di.New(
+			di.Provide(func() http.Server { return http.Server{} }),
+			di.Provide(func() *http.ServeMux { return &http.ServeMux{} }),
+			di.ProvideValue(":8080", di.Tags{"name": "addr"}),
+		)
+		require.NoError(t, err)
+		var missing *di.MissingError
+		err = c.Resolve(new(*http.Server))
+		require.True(t, errors.As(err, &missing))
+		require.Len(t, missing.Suggestions, 1)
+		require.Regexp(t, `: type \*http.Server not exists in the container, did you mean http.Server at .*container_test.go:\d+, not a pointer\?$`, err.Error())
+		err = c.Resolve(new(string), di.Tags{"name": "adr"})
+		require.Regexp(t, `did you mean string\[name:addr\] at .*container_test.go:\d+ with other tags\?$`, err.Error())
+		err = c.Resolve(new(http.Handler))
+		require.Regexp(t, `did you mean \*http.ServeMux at .*container_test.go:\d+, provide it with di.As\(new\(http.Handler\)\)\?$`, err.Error())
+		err = c.Resolve(new(*http.Request))
+		require.True(t, errors.As(err, &missing))
+		require.Empty(t, missing.Suggestions)
+	})
+
+	t.Run("private type", func(t *testing.T) {
+		c, err := di.New(
+			di.Module("http",
+				di.Provide(func() *http.Server { return &http.Server{} }, di.Private()),
+			),
+		)
+		require.NoError(t, err)
+		var server *http.Server
+		err = c.Resolve(&server)
+		var missing *di.MissingError
+		require.True(t, errors.As(err, &missing))
+		require.Equal(t, "http", missing.Module)
+		require.True(t, errors.Is(err, di.ErrTypeNotExists))
+	})
+
+	t.Run("ambiguous dependency", func(t *testing.T) {
+		c, err := di.New(
+			di.ProvideValue(":8080", di.Tags{"name": "addr"}),
+			di.ProvideValue(":9090", di.Tags{"name": "metrics"}),
+			di.Provide(func(addr string) *http.Server { return &http.Server{Addr: addr} }),
+		)
+		require.NoError(t, err)
+		var server *http.Server
+		err = c.Resolve(&server)
+		require.Contains(t, err.Error(), ": *http.Server: multiple definitions of string, maybe you need to use group type: []string; candidates: string[name:addr] at ")
+		var ambiguous *di.AmbiguousError
+		require.True(t, errors.As(err, &ambiguous))
+		require.Equal(t, reflect.TypeOf(""), ambiguous.Type)
+		require.Len(t, ambiguous.Candidates, 2)
+		require.Equal(t, []string{"*http.Server"}, ambiguous.Path)
+	})
+
+	t.Run("invalid signature", func(t *testing.T) {
+		c, err := di.New()
+		require.NoError(t, err)
+		err = c.Provide(func() {})
+		require.Contains(t, err.Error(), ": invalid constructor signature, got func()")
+		var signature *di.SignatureError
+		require.True(t, errors.As(err, &signature))
+		require.Equal(t, "constructor", signature.Kind)
+		require.Equal(t, reflect.TypeOf(func() {}), signature.Type)
+		require.Contains(t, signature.Frame, "container_test.go:")
+		err = c.Invoke(func() *http.Server { return nil })
+		require.True(t, errors.As(err, &signature))
+		require.Equal(t, "invocation", signature.Kind)
+		require.EqualError(t, signature, "invalid invocation signature, got func() *http.Server")
+		require.Contains(t, signature.Frame, "container_test.go:")
+		err = c.Decorate(func() {})
+		require.True(t, errors.As(err, &signature))
+		require.Equal(t, "decorator", signature.Kind)
+		require.Contains(t, signature.Frame, "container_test.go:")
+		_, err = di.New(di.Invoke(func() *http.Server { return nil }))
+		require.True(t, errors.As(err, &signature))
+		require.Contains(t, signature.Frame, "container_test.go:")
+	})
+
+	t.Run("constructor error", func(t *testing.T) {
+		c, err := di.New(
+			di.Provide(func() (*http.ServeMux, error) { return nil, io.ErrUnexpectedEOF }),
+			di.Provide(func(mux *http.ServeMux) *http.Server { return &http.Server{Handler: mux} }),
+		)
+		require.NoError(t, err)
+		var server *http.Server
+		err = c.Resolve(&server)
+		require.Contains(t, err.Error(), ": *http.Server: *http.ServeMux: unexpected EOF")
+		require.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+		var ctorErr *di.ConstructorError
+		require.True(t, errors.As(err, &ctorErr))
+		require.Equal(t, reflect.TypeOf(&http.ServeMux{}), ctorErr.Type)
+		require.Equal(t, []string{"*http.Server", "*http.ServeMux"}, ctorErr.Path)
+		require.Contains(t, ctorErr.Frame, "container_test.go:")
+	})
+
+	t.Run("full dependency path", func(t *testing.T) {
+		type Handler struct {
+			di.Inject
+			Server *http.Server
+		}
+		c, err := di.New(
+			di.Provide(func(addr string) *http.Server { return &http.Server{Addr: addr} }),
+			di.Provide(func() *Handler { return &Handler{} }),
+			di.Provide(func(mux *http.ServeMux, handler *Handler) *http.Client { return &http.Client{} }),
+			di.Provide(func() *http.ServeMux { return &http.ServeMux{} }),
+		)
+		require.NoError(t, err)
+		err = c.Resolve(new(*http.Client))
+		var missing *di.MissingError
+		require.True(t, errors.As(err, &missing))
+		require.Len(t, missing.Hops, 3)
+		require.Equal(t, 1, missing.Hops[0].Param)
+		require.Equal(t, "Server", missing.Hops[1].Field)
+		require.Equal(t, -1, missing.Hops[1].Param)
+		require.Equal(t, 0, missing.Hops[2].Param)
+		for _, hop := range missing.Hops {
+			require.Contains(t, hop.Frame, "container_test.go:")
+		}
+		require.Regexp(t, `^.*: type string not exists in the container
+	\*http.Client at .*container_test.go:\d+
+	parameter 1: \*di_test.Handler at .*container_test.go:\d+
+	field Server: \*http.Server at .*container_test.go:\d+
+	parameter 0: string$`, fmt.Sprintf("%+v", err))
+		require.Equal(t, err.Error(), fmt.Sprintf("%v", err))
+	})
+
+	t.Run("full dependency path of option error", func(t *testing.T) {
+		_, err := di.New(
+			di.Provide(func(addr string) *http.Server { return &http.Server{Addr: addr} }),
+			di.Invoke(func(server *http.Server) {}),
+		)
+		require.Regexp(t, `^.*container_test.go:\d+: \*http.Server: type string not exists in the container
+	\*http.Server at .*container_test.go:\d+
+	parameter 0: string$`, fmt.Sprintf("%+v", err))
+	})
+
+	t.Run("full dependency path of constructor error", func(t *testing.T) {
+		type Handler struct {
+			di.Inject
+			Mux *http.ServeMux
+		}
+		c, err := di.New(
+			di.Provide(func() (*http.ServeMux, error) { return nil, io.ErrUnexpectedEOF }),
+			di.Provide(func() *Handler { return &Handler{} }),
+		)
+		require.NoError(t, err)
+		err = c.Resolve(new(*Handler))
+		var ctorErr *di.ConstructorError
+		require.True(t, errors.As(err, &ctorErr))
+		require.Equal(t, []string{"*di_test.Handler", "*http.ServeMux"}, ctorErr.Path)
+		require.Equal(t, "Mux", ctorErr.Hops[0].Field)
+		require.Regexp(t, `^.*: unexpected EOF
+	\*di_test.Handler at .*container_test.go:\d+
+	field Mux: \*http.ServeMux at .*container_test.go:\d+$`, fmt.Sprintf("%+v", err))
+	})
+}
+
+func TestContainer_ResolveContext(t *testing.T) {
+	type key struct{}
+	t.Run("constructor receives context of resolution", func(t *testing.T) {
+		c, err := di.New(
+			di.Provide(func(ctx context.Context) *http.Server {
+				return &http.Server{Addr: ctx.Value(key{}).(string)}
+			}),
+		)
+		require.NoError(t, err)
+		var server *http.Server
+		require.NoError(t, c.ResolveContext(context.WithValue(context.Background(), key{}, ":8080"), &server))
+		require.Equal(t, ":8080", server.Addr)
+	})
+
+	t.Run("constructor receives background context without context of resolution", func(t *testing.T) {
+		c, err := di.New(
+			di.Provide(func(ctx context.Context) *http.Server {
+				require.Equal(t, context.Background(), ctx)
+				return &http.Server{}
+			}),
+		)
+		require.NoError(t, err)
+		var server *http.Server
+		require.NoError(t, c.Resolve(&server))
+	})
+
+	t.Run("provided context is used without context of resolution", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), key{}, ":8080")
+		c, err := di.New(
+			di.ProvideValue(ctx, di.As(new(context.Context))),
+			di.Provide(func(ctx context.Context) *http.Server {
+				return &http.Server{Addr: ctx.Value(key{}).(string)}
+			}),
+		)
+		require.NoError(t, err)
+		var server *http.Server
+		require.NoError(t, c.Resolve(&server))
+		require.Equal(t, ":8080", server.Addr)
+	})
+
+	t.Run("resolution aborted when context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		var serverCalled bool
+		c, err := di.New(
+			di.Provide(func() *http.ServeMux {
+				cancel()
+				return &http.ServeMux{}
+			}),
+			di.Provide(func(mux *http.ServeMux) *http.Server {
+				serverCalled = true
+				return &http.Server{Handler: mux}
+			}),
+		)
+		require.NoError(t, err)
+		var server *http.Server
+		err = c.ResolveContext(ctx, &server)
+		require.Error(t, err)
+		require.True(t, errors.Is(err, context.Canceled))
+		require.False(t, serverCalled)
+		var mux *http.ServeMux
+		require.NoError(t, c.Resolve(&mux))
+	})
+
+	t.Run("decorator dependency receives context of resolution", func(t *testing.T) {
+		c, err := di.New(
+			di.Provide(func() *http.Server { return &http.Server{} }),
+			di.Provide(func(ctx context.Context) *http.ServeMux {
+				require.Equal(t, ":8080", ctx.Value(key{}))
+				return &http.ServeMux{}
+			}),
+			di.Wrap(func(server *http.Server, mux *http.ServeMux) *http.Server {
+				return &http.Server{Handler: mux}
+			}),
+		)
+		require.NoError(t, err)
+		var server *http.Server
+		require.NoError(t, c.ResolveContext(context.WithValue(context.Background(), key{}, ":8080"), &server))
+		require.NotNil(t, server.Handler)
+	})
+
+	t.Run("decorator dependency aborted when context is cancelled", func(t *testing.T) {
+		type Prefix string
+		ctx, cancel := context.WithCancel(context.Background())
+		var muxCalled bool
+		c, err := di.New(
+			di.Provide(func() *http.Server { return &http.Server{} }),
+			di.Provide(func() Prefix {
+				cancel()
+				return "/"
+			}),
+			di.Provide(func(prefix Prefix) *http.ServeMux {
+				muxCalled = true
+				return &http.ServeMux{}
+			}),
+			di.Wrap(func(server *http.Server, mux *http.ServeMux) *http.Server {
+				return &http.Server{Handler: mux}
+			}),
+		)
+		require.NoError(t, err)
+		var server *http.Server
+		err = c.ResolveContext(ctx, &server)
+		require.Error(t, err)
+		require.True(t, errors.Is(err, context.Canceled))
+		require.False(t, muxCalled)
+	})
+
+	t.Run("invocation receives context of resolution", func(t *testing.T) {
+		c, err := di.New(
+			di.Provide(func(ctx context.Context) *http.Server {
+				return &http.Server{Addr: ctx.Value(key{}).(string)}
+			}),
+		)
+		require.NoError(t, err)
+		ctx := context.WithValue(context.Background(), key{}, ":8080")
+		err = c.InvokeContext(ctx, func(invoked context.Context, server *http.Server) error {
+			require.Equal(t, ctx, invoked)
+			require.Equal(t, ":8080", server.Addr)
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("invocation aborted when context is cancelled", func(t *testing.T) {
+		c, err := di.New(
+			di.Provide(func() *http.Server { return &http.Server{} }),
+		)
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err = c.InvokeContext(ctx, func(server *http.Server) {})
+		require.True(t, errors.Is(err, context.Canceled))
+	})
+}
+
+func TestContainer_Retry(t *testing.T) {
+	t.Run("constructor is called until success", func(t *testing.T) {
+		var calls int
+		var cleanupCalls int
+		c, err := di.New(
+			di.Provide(func() (*http.Server, func(), error) {
+				calls++
+				if calls < 3 {
+					return &http.Server{}, func() { cleanupCalls++ }, fmt.Errorf("dial failed")
+				}
+				return &http.Server{}, func() { cleanupCalls++ }, nil
+			}, di.Retry(di.RetryPolicy{Attempts: 5, Delay: time.Millisecond})),
+		)
+		require.NoError(t, err)
+		var server *http.Server
+		require.NoError(t, c.Resolve(&server))
+		require.Equal(t, 3, calls)
+		require.Equal(t, 2, cleanupCalls)
+		c.Cleanup()
+		require.Equal(t, 3, cleanupCalls)
+	})
+
+	t.Run("all attempts failed", func(t *testing.T) {
+		var calls int
+		c, err := di.New(
+			di.Provide(func() (*http.Server, error) {
+				calls++
+				return nil, fmt.Errorf("dial failed")
+			}, di.Retry(di.RetryPolicy{Attempts: 3})),
+		)
+		require.NoError(t, err)
+		var server *http.Server
+		err = c.Resolve(&server)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), ": *http.Server: failed after 3 attempts: dial failed")
+		var retryErr *di.RetryError
+		require.True(t, errors.As(err, &retryErr))
+		require.Equal(t, 3, retryErr.Attempts)
+		require.Equal(t, 3, calls)
+	})
+
+	t.Run("backoff is aborted when context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		var calls int
+		c, err := di.New(
+			di.Provide(func() (*http.Server, error) {
+				calls++
+				cancel()
+				return nil, fmt.Errorf("dial failed")
+			}, di.Retry(di.RetryPolicy{Attempts: 3, Delay: time.Hour})),
+		)
+		require.NoError(t, err)
+		var server *http.Server
+		err = c.ResolveContext(ctx, &server)
+		require.True(t, errors.Is(err, context.Canceled))
+		require.Equal(t, 1, calls)
+	})
+}
+
+func TestContainer_Timeout(t *testing.T) {
+	t.Run("context parameter is cancelled on timeout", func(t *testing.T) {
+		c, err := di.New(
+			di.Provide(func(ctx context.Context) (*http.Server, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}, di.Timeout(time.Millisecond)),
+		)
+		require.NoError(t, err)
+		var server *http.Server
+		err = c.Resolve(&server)
+		require.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("slow constructor result is cleaned up", func(t *testing.T) {
+		cleanupCalled := make(chan struct{})
+		c, err := di.New(
+			di.Provide(func() (*http.Server, func()) {
+				time.Sleep(10 * time.Millisecond)
+				return &http.Server{}, func() { close(cleanupCalled) }
+			}, di.Timeout(time.Millisecond)),
+		)
+		require.NoError(t, err)
+		var server *http.Server
+		err = c.Resolve(&server)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), ": *http.Server: timeout 1ms exceeded: context deadline exceeded")
+		var timeoutErr *di.TimeoutError
+		require.True(t, errors.As(err, &timeoutErr))
+		require.Equal(t, time.Millisecond, timeoutErr.Timeout)
+		select {
+		case <-cleanupCalled:
+		case <-time.After(time.Second):
+			t.Fatal("cleanup of late result is not called")
+		}
+	})
+
+	t.Run("constructor without context is interrupted", func(t *testing.T) {
+		c, err := di.New(
+			di.Provide(func() *http.Server {
+				time.Sleep(time.Second)
+				return &http.Server{}
+			}, di.Timeout(10*time.Millisecond)),
+		)
+		require.NoError(t, err)
+		start := time.Now()
+		err = c.Resolve(new(*http.Server))
+		require.True(t, errors.Is(err, context.DeadlineExceeded))
+		require.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+
+	t.Run("constructor within timeout keeps result", func(t *testing.T) {
+		c, err := di.New(
+			di.Provide(func() *http.Server {
+				time.Sleep(time.Millisecond)
+				return &http.Server{Addr: ":8080"}
+			}, di.Timeout(time.Second)),
+		)
+		require.NoError(t, err)
+		var server *http.Server
+		require.NoError(t, c.Resolve(&server))
+		require.Equal(t, ":8080", server.Addr)
+	})
+
+	t.Run("context of successful call stays alive", func(t *testing.T) {
+		var kept context.Context
+		c, err := di.New(
+			di.Provide(func(ctx context.Context) *http.Server {
+				kept = ctx
+				return &http.Server{}
+			}, di.Timeout(time.Millisecond)),
+		)
+		require.NoError(t, err)
+		require.NoError(t, c.Resolve(new(*http.Server)))
+		time.Sleep(5 * time.Millisecond)
+		require.NoError(t, kept.Err())
+	})
+
+	t.Run("panic is recovered in resolving goroutine", func(t *testing.T) {
+		c, err := di.New(
+			di.RecoverPanics(),
+			di.Provide(func() *http.Server { panic("boom") }, di.Timeout(time.Second)),
+		)
+		require.NoError(t, err)
+		var panicErr *di.PanicError
+		require.True(t, errors.As(c.Resolve(new(*http.Server)), &panicErr))
+		require.Equal(t, "boom", panicErr.Value)
+		// the stack is taken in goroutine of constructor call
+		require.NotContains(t, string(panicErr.Stack), "(*Container).Resolve")
+	})
+
+	t.Run("late panic is recovered", func(t *testing.T) {
+		panicked := make(chan struct{})
+		c, err := di.New(
+			di.RecoverPanics(),
+			di.Provide(func() *http.Server {
+				defer close(panicked)
+				time.Sleep(10 * time.Millisecond)
+				panic("boom")
+			}, di.Timeout(time.Millisecond)),
+		)
+		require.NoError(t, err)
+		err = c.Resolve(new(*http.Server))
+		require.True(t, errors.Is(err, context.DeadlineExceeded))
+		<-panicked
+	})
+
+	t.Run("each attempt is limited", func(t *testing.T) {
+		var calls int
+		c, err := di.New(
+			di.Provide(func(ctx context.Context) (*http.Server, error) {
+				calls++
+				if calls == 1 {
+					<-ctx.Done()
+					return nil, ctx.Err()
+				}
+				return &http.Server{}, nil
+			}, di.Timeout(time.Millisecond), di.Retry(di.RetryPolicy{Attempts: 2})),
+		)
+		require.NoError(t, err)
+		var server *http.Server
+		require.NoError(t, c.Resolve(&server))
+		require.Equal(t, 2, calls)
+	})
+}
+
+func TestContainer_RecoverPanics(t *testing.T) {
+	t.Run("constructor panic", func(t *testing.T) {
+		c, err := di.New(
+			di.RecoverPanics(),
+			di.Provide(func() *http.ServeMux { panic("boom") }),
+			di.Provide(func(mux *http.ServeMux) *http.Server { return &http.Server{Handler: mux} }),
+		)
+		require.NoError(t, err)
+		var server *http.Server
+		err = c.Resolve(&server)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), ": *http.Server: *http.ServeMux: panic: boom")
+		var panicErr *di.PanicError
+		require.True(t, errors.As(err, &panicErr))
+		require.Equal(t, "boom", panicErr.Value)
+		require.Equal(t, []string{"*http.Server", "*http.ServeMux"}, panicErr.Path)
+		require.Contains(t, string(panicErr.Stack), "panic")
+	})
+
+	t.Run("decorator panic", func(t *testing.T) {
+		c, err := di.New(
+			di.RecoverPanics(),
+			di.Provide(func() *http.Server { return &http.Server{} }),
+			di.Wrap(func(server *http.Server) *http.Server { panic("boom") }),
+		)
+		require.NoError(t, err)
+		var server *http.Server
+		err = c.Resolve(&server)
+		var panicErr *di.PanicError
+		require.True(t, errors.As(err, &panicErr))
+		require.Equal(t, []string{"*http.Server"}, panicErr.Path)
+	})
+
+	t.Run("invocation panic with error", func(t *testing.T) {
+		c, err := di.New(
+			di.RecoverPanics(),
+		)
+		require.NoError(t, err)
+		err = c.Invoke(func() { panic(io.ErrUnexpectedEOF) })
+		require.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+		require.EqualError(t, err, "panic: unexpected EOF")
+	})
+
+	t.Run("panics are not recovered by default", func(t *testing.T) {
+		c, err := di.New(
+			di.Provide(func() *http.Server { panic("boom") }),
+		)
+		require.NoError(t, err)
+		require.PanicsWithValue(t, "boom", func() {
+			var server *http.Server
+			_ = c.Resolve(&server)
+		})
+	})
+}
+
+func TestContainer_Invalidate(t *testing.T) {
+	t.Run("dependents are rebuilt with fresh dependencies", func(t *testing.T) {
+		addr := ":8080"
+		var cleanupCalls []string
+		c, err := di.New(
+			di.Provide(func() *http.ServeMux { return &http.ServeMux{} }, di.As(new(http.Handler))),
+			di.Provide(func() string { return addr }),
+			di.Provide(func(addr string, handler http.Handler) (*http.Server, func()) {
+				return &http.Server{Addr: addr, Handler: handler}, func() { cleanupCalls = append(cleanupCalls, "server "+addr) }
+			}),
+		)
+		require.NoError(t, err)
+		var server *http.Server
+		require.NoError(t, c.Resolve(&server))
+		var mux *http.ServeMux
+		require.NoError(t, c.Resolve(&mux))
+		addr = ":9090"
+		require.NoError(t, c.Invalidate(new(string)))
+		require.Equal(t, []string{"server :8080"}, cleanupCalls)
+		var rebuilt *http.Server
+		require.NoError(t, c.Resolve(&rebuilt))
+		require.NotSame(t, server, rebuilt)
+		require.Equal(t, ":9090", rebuilt.Addr)
+		var same *http.ServeMux
+		require.NoError(t, c.Resolve(&same))
+		require.Same(t, mux, same)
+		c.Cleanup()
+		require.Equal(t, []string{"server :8080", "server :9090"}, cleanupCalls)
+	})
+
+	t.Run("transitive dependents cleaned up in reverse order", func(t *testing.T) {
+		var cleanupCalls []string
+		c, err := di.New(
+			di.Provide(func() (*http.ServeMux, func()) {
+				return &http.ServeMux{}, func() { cleanupCalls = append(cleanupCalls, "mux") }
+			}, di.As(new(http.Handler))),
+			di.Provide(func(handler http.Handler) (*http.Server, func()) {
+				return &http.Server{Handler: handler}, func() { cleanupCalls = append(cleanupCalls, "server") }
+			}),
+			di.Provide(func(servers []*http.Server) (*http.Client, func()) {
+				return &http.Client{}, func() { cleanupCalls = append(cleanupCalls, "client") }
+			}),
+		)
+		require.NoError(t, err)
+		var client *http.Client
+		require.NoError(t, c.Resolve(&client))
+		require.NoError(t, c.Invalidate(new(http.Handler)))
+		require.Equal(t, []string{"client", "server", "mux"}, cleanupCalls)
+		var rebuilt *http.Client
+		require.NoError(t, c.Resolve(&rebuilt))
+		require.NotSame(t, client, rebuilt)
+	})
+
+	t.Run("injected fields and decorators", func(t *testing.T) {
+		type Params struct {
+			di.Inject
+			Mux *http.ServeMux
+		}
+		var built int
+		c, err := di.New(
+			di.Provide(func() *http.ServeMux { built++; return &http.ServeMux{} }),
+			di.Provide(func() *http.Server { return &http.Server{} }),
+			di.Provide(func(params Params) *http.Client { return &http.Client{} }),
+			di.Wrap(func(server *http.Server, mux *http.ServeMux) *http.Server {
+				return &http.Server{Handler: mux}
+			}),
+		)
+		require.NoError(t, err)
+		var server *http.Server
+		require.NoError(t, c.Resolve(&server))
+		var client *http.Client
+		require.NoError(t, c.Resolve(&client))
+		require.NoError(t, c.Invalidate(new(*http.ServeMux)))
+		var rebuiltServer *http.Server
+		require.NoError(t, c.Resolve(&rebuiltServer))
+		require.NotSame(t, server, rebuiltServer)
+		var rebuiltClient *http.Client
+		require.NoError(t, c.Resolve(&rebuiltClient))
+		require.NotSame(t, client, rebuiltClient)
+		require.Equal(t, 2, built)
+	})
+
+	t.Run("not existing type", func(t *testing.T) {
+		c, err := di.New()
+		require.NoError(t, err)
+		err = c.Invalidate(new(*http.Server))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), ": type *http.Server not exists in the container")
+		require.True(t, errors.Is(err, di.ErrTypeNotExists))
+	})
+}
+
+func TestContainer_Reload(t *testing.T) {
+	type Config struct {
+		Addr string
+	}
+	t.Run("dependents are swapped and old cleanups run", func(t *testing.T) {
+		addr := ":8080"
+		var cleanupCalls []string
+		c, err := di.New(
+			di.Provide(func() (*Config, func()) {
+				config := &Config{Addr: addr}
+				return config, func() { cleanupCalls = append(cleanupCalls, "config "+config.Addr) }
+			}, di.Reloadable()),
+			di.Provide(func(config *Config) (*http.Server, func()) {
+				return &http.Server{Addr: config.Addr}, func() { cleanupCalls = append(cleanupCalls, "server "+config.Addr) }
+			}),
+			di.Provide(func(server di.Live[*http.Server]) *http.Client { return &http.Client{} }),
+		)
+		require.NoError(t, err)
+		var live di.Live[*http.Server]
+		require.NoError(t, c.Resolve(&live))
+		var client *http.Client
+		require.NoError(t, c.Resolve(&client))
+		require.Equal(t, ":8080", live.Load().Addr)
+		addr = ":9090"
+		require.NoError(t, c.Reload(new(*Config)))
+		require.Equal(t, []string{"server :8080", "config :8080"}, cleanupCalls)
+		require.Equal(t, ":9090", live.Load().Addr)
+		var server *http.Server
+		require.NoError(t, c.Resolve(&server))
+		require.Same(t, server, live.Load())
+		var same *http.Client
+		require.NoError(t, c.Resolve(&same))
+		require.Same(t, client, same)
+		c.Cleanup()
+		require.Equal(t, []string{"server :8080", "config :8080", "server :9090", "config :9090"}, cleanupCalls)
+	})
+
+	t.Run("failed reload keeps old generation", func(t *testing.T) {
+		var fail bool
+		var cleanupCalls []string
+		c, err := di.New(
+			di.Provide(func() (*Config, func()) {
+				return &Config{}, func() { cleanupCalls = append(cleanupCalls, "config") }
+			}, di.Reloadable()),
+			di.Provide(func(config *Config) (*http.Server, error) {
+				if fail {
+					return nil, fmt.Errorf("invalid config")
+				}
+				return &http.Server{}, nil
+			}),
+		)
+		require.NoError(t, err)
+		var live di.Live[*http.Server]
+		require.NoError(t, c.Resolve(&live))
+		server := live.Load()
+		fail = true
+		err = c.Reload(new(*Config))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), ": *http.Server: invalid config")
+		require.Equal(t, []string{"config"}, cleanupCalls)
+		require.Same(t, server, live.Load())
+		var resolved *http.Server
+		require.NoError(t, c.Resolve(&resolved))
+		require.Same(t, server, resolved)
+		c.Cleanup()
+		require.Equal(t, []string{"config", "config"}, cleanupCalls)
+	})
+
+	t.Run("concurrent resolve gets old generation until publish", func(t *testing.T) {
+		var reloading bool
+		building := make(chan struct{})
+		release := make(chan struct{})
+		c, err := di.New(
+			di.Provide(func() *Config {
+				if !reloading {
+					return &Config{Addr: ":8080"}
+				}
+				close(building)
+				<-release
+				return &Config{Addr: ":9090"}
+			}, di.Reloadable()),
+			di.Provide(func(config *Config) *http.Server { return &http.Server{Addr: config.Addr} }),
+		)
+		require.NoError(t, err)
+		var server *http.Server
+		require.NoError(t, c.Resolve(&server))
+		reloading = true
+		done := make(chan error)
+		go func() { done <- c.Reload(new(*Config)) }()
+		<-building
+		var resolved *http.Server
+		require.NoError(t, c.Resolve(&resolved))
+		require.Same(t, server, resolved)
+		var config *Config
+		require.NoError(t, c.Resolve(&config))
+		require.Equal(t, ":8080", config.Addr)
+		close(release)
+		require.NoError(t, <-done)
+		require.NoError(t, c.Resolve(&resolved))
+		require.Equal(t, ":9090", resolved.Addr)
+	})
+
+	t.Run("decorator gets reloaded dependency", func(t *testing.T) {
+		addr := ":8080"
+		c, err := di.New(
+			di.Provide(func() *Config { return &Config{Addr: addr} }, di.Reloadable()),
+			di.Provide(func() *http.Server { return &http.Server{} }),
+			di.Wrap(func(server *http.Server, config *Config) *http.Server {
+				return &http.Server{Addr: config.Addr}
+			}),
+		)
+		require.NoError(t, err)
+		var server *http.Server
+		require.NoError(t, c.Resolve(&server))
+		require.Equal(t, ":8080", server.Addr)
+		addr = ":9090"
+		require.NoError(t, c.Reload(new(*Config)))
+		require.NoError(t, c.Resolve(&server))
+		require.Equal(t, ":9090", server.Addr)
+	})
+
+	t.Run("live handles are resolved concurrently with reload", func(t *testing.T) {
+		for i := 0; i < 50; i++ {
+			c, err := di.New(
+				di.Provide(func() *Config { return &Config{Addr: ":8080"} }, di.Reloadable()),
+			)
+			require.NoError(t, err)
+			var config *Config
+			require.NoError(t, c.Resolve(&config))
+			started := make(chan struct{})
+			done := make(chan error)
+			go func() {
+				close(started)
+				for j := 0; j < 10; j++ {
+					if err := c.Reload(new(*Config)); err != nil {
+						done <- err
+						return
+					}
+				}
+				done <- nil
+			}()
+			<-started
+			var live di.Live[*Config]
+			require.NoError(t, c.Resolve(&live))
+			require.Equal(t, ":8080", live.Load().Addr)
+			require.NoError(t, <-done)
+		}
+	})
+
+	t.Run("live handle of not built type", func(t *testing.T) {
+		type Params struct {
+			di.Inject
+			Config di.Live[*Config] `di:"name=main"`
+		}
+		c, err := di.New(
+			di.Provide(func() *Config { return &Config{Addr: ":8080"} }, di.Tags{"name": "main"}, di.Reloadable()),
+		)
+		require.NoError(t, err)
+		var params Params
+		require.NoError(t, c.Resolve(&params))
+		require.Equal(t, ":8080", params.Config.Load().Addr)
+		require.Nil(t, di.Live[*Config]{}.Load())
+	})
+
+	t.Run("value resolved in child container is not reloaded", func(t *testing.T) {
+		var cleanupCalls int
+		parent, err := di.New(
+			di.Provide(func() (*Config, func()) {
+				return &Config{}, func() { cleanupCalls++ }
+			}, di.Reloadable()),
+		)
+		require.NoError(t, err)
+		child, err := di.New(
+			di.Provide(func(config *Config) *http.Server { return &http.Server{Addr: config.Addr} }),
+		)
+		require.NoError(t, err)
+		require.NoError(t, child.AddParent(parent))
+		var server *http.Server
+		require.NoError(t, child.Resolve(&server))
+		err = parent.Reload(new(*Config))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), ": *di_test.Config is resolved in child container, it can't be reloaded")
+		require.Zero(t, cleanupCalls)
+	})
+
+	t.Run("not reloadable type", func(t *testing.T) {
+		c, err := di.New(
+			di.Provide(func() *Config { return &Config{} }),
+		)
+		require.NoError(t, err)
+		err = c.Reload(new(*Config))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), ": *di_test.Config is not reloadable, use di.Reloadable() provide option")
+	})
+}
+
 func TestContainer_AddParent(t *testing.T) {
 	t.Run("provide ancestor and resolve in child", func(t *testing.T) {
 		papaw, err := di.New()
 		require.NoError(t, err)
-		require.NotNil(t, papaw)
-		parent, err := di.New()
+		require.NotNil(t, papaw)
+		parent, err := di.New()
+		require.NoError(t, err)
+		require.NotNil(t, parent)
+		child, err := di.New()
+		require.NoError(t, err)
+		require.NotNil(t, child)
+
+		require.NoError(t, parent.AddParent(papaw))
+		require.NoError(t, child.AddParent(parent))
+
+		conn1 := &net.TCPConn{}
+		require.NoError(t, papaw.Provide(func() *net.TCPConn { return conn1 }))
+
+		var conn *net.TCPConn
+		require.NoError(t, child.Resolve(&conn))
+		require.Equal(t, fmt.Sprintf("%p", conn1), fmt.Sprintf("%p", conn))
+	})
+
+	t.Run("resolve multiple type instances across ancestors", func(t *testing.T) {
+		papaw, err := di.New()
+		require.NoError(t, err)
+		require.NotNil(t, papaw)
+		parent, err := di.New()
+		require.NoError(t, err)
+		require.NotNil(t, parent)
+		child, err := di.New()
+		require.NoError(t, err)
+		require.NotNil(t, child)
+
+		require.NoError(t, parent.AddParent(papaw))
+		require.NoError(t, child.AddParent(parent))
+
+		conn1 := &net.TCPConn{}
+		conn2 := &net.TCPConn{}
+		conn3 := &net.TCPConn{}
+		require.NoError(t, papaw.Provide(func() *net.TCPConn { return conn1 }))
+		require.NoError(t, parent.Provide(func() *net.TCPConn { return conn2 }))
+		require.NoError(t, child.Provide(func() *net.TCPConn { return conn3 }))
+
+		var conns []*net.TCPConn
+		require.NoError(t, child.Resolve(&conns))
+		require.Len(t, conns, 3)
+		require.Equal(t, fmt.Sprintf("%p", conn1), fmt.Sprintf("%p", conns[0]))
+		require.Equal(t, fmt.Sprintf("%p", conn2), fmt.Sprintf("%p", conns[1]))
+		require.Equal(t, fmt.Sprintf("%p", conn3), fmt.Sprintf("%p", conns[2]))
+	})
+
+	t.Run("add parent errors", func(t *testing.T) {
+		parent, err := di.New()
+		require.NoError(t, err)
+		require.NotNil(t, parent)
+		err = parent.AddParent(parent)
+		require.Contains(t, err.Error(), "self cycle detected")
+		child, err := di.New()
+		require.NoError(t, err)
+		require.NotNil(t, child)
+		err = child.AddParent(parent)
+		require.NoError(t, err)
+		err = parent.AddParent(child)
+		require.Contains(t, err.Error(), "cycle detected")
+		err = child.AddParent(parent)
+		require.Contains(t, err.Error(), "parent already chained")
+		papaw, err := di.New()
+		require.NoError(t, err)
+		require.NotNil(t, papaw)
+		err = parent.AddParent(papaw)
+		err = papaw.AddParent(child)
+		require.Contains(t, err.Error(), "cycle detected")
+	})
+
+}
+
+func TestContainer_Primary(t *testing.T) {
+	t.Run("untagged resolve picks primary definition", func(t *testing.T) {
+		leader := &http.Server{}
+		follower := &http.Server{}
+		c, err := di.New(
+			di.Provide(func() *http.Server { return leader }, di.Primary(), di.Tags{"type": "leader"}),
+			di.Provide(func() *http.Server { return follower }, di.Tags{"type": "follower"}),
+		)
+		require.NoError(t, err)
+		var server *http.Server
+		require.NoError(t, c.Resolve(&server))
+		require.Equal(t, fmt.Sprintf("%p", leader), fmt.Sprintf("%p", server))
+		require.NoError(t, c.Resolve(&server, di.Tags{"type": "follower"}))
+		require.Equal(t, fmt.Sprintf("%p", follower), fmt.Sprintf("%p", server))
+		var servers []*http.Server
+		require.NoError(t, c.Resolve(&servers))
+		require.Len(t, servers, 2)
+	})
+
+	t.Run("primary interface definition", func(t *testing.T) {
+		server := &http.Server{}
+		c, err := di.New(
+			di.Provide(func() *http.Server { return server }, di.As(new(io.Closer)), di.Primary()),
+			di.Provide(func() *os.File { return &os.File{} }, di.As(new(io.Closer))),
+		)
+		require.NoError(t, err)
+		var closer io.Closer
+		require.NoError(t, c.Resolve(&closer))
+		require.Equal(t, fmt.Sprintf("%p", server), fmt.Sprintf("%p", closer))
+	})
+
+	t.Run("several primary definitions cause error", func(t *testing.T) {
+		c, err := di.New(
+			di.Provide(http.NewServeMux, di.Primary()),
+			di.Provide(http.NewServeMux, di.Primary(), di.Tags{"name": "second"}),
+		)
+		require.NoError(t, err)
+		var mux *http.ServeMux
+		err = c.Resolve(&mux)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "multiple definitions of *http.ServeMux")
+		require.Contains(t, err.Error(), "candidates: *http.ServeMux at ")
+		require.Contains(t, err.Error(), "*http.ServeMux[name:second] at ")
+		require.Contains(t, err.Error(), "container_test.go:")
+	})
+
+	t.Run("prefer untagged policy", func(t *testing.T) {
+		mux := &http.ServeMux{}
+		c, err := di.New(
+			di.WithDefaultPolicy(di.PreferUntagged),
+			di.Provide(func() *http.ServeMux { return mux }),
+			di.Provide(http.NewServeMux, di.Tags{"name": "admin"}),
+		)
+		require.NoError(t, err)
+		var result *http.ServeMux
+		require.NoError(t, c.Resolve(&result))
+		require.Equal(t, fmt.Sprintf("%p", mux), fmt.Sprintf("%p", result))
+	})
+
+	t.Run("prefer untagged policy with several untagged definitions cause error", func(t *testing.T) {
+		c, err := di.New(
+			di.WithDefaultPolicy(di.PreferUntagged),
+			di.Provide(http.NewServeMux),
+			di.Provide(http.NewServeMux),
+		)
+		require.NoError(t, err)
+		var result *http.ServeMux
+		err = c.Resolve(&result)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "multiple definitions of *http.ServeMux")
+	})
+}
+
+func TestContainer_GroupOrder(t *testing.T) {
+	t.Run("group members sorted by order", func(t *testing.T) {
+		type MyFunc func() string
+		c, err := di.New(
+			di.Provide(func() MyFunc { return func() string { return "auth" } }, di.Order(10)),
+			di.Provide(func() MyFunc { return func() string { return "logging" } }),
+			di.Provide(func() MyFunc { return func() string { return "recovery" } }, di.Order(-10)),
+		)
+		require.NoError(t, err)
+		var funcs []MyFunc
+		require.NoError(t, c.Resolve(&funcs))
+		var result []string
+		for _, fn := range funcs {
+			result = append(result, fn())
+		}
+		require.Equal(t, []string{"recovery", "logging", "auth"}, result)
+	})
+
+	t.Run("before and after constraints", func(t *testing.T) {
+		type Migration func() string
+		c, err := di.New(
+			di.Provide(func() Migration { return func() string { return "orders" } },
+				di.Tags{"migration": "orders"},
+				di.After(di.Tags{"migration": "users"}),
+			),
+			di.Provide(func() Migration { return func() string { return "users" } },
+				di.Tags{"migration": "users"},
+			),
+			di.Provide(func() Migration { return func() string { return "schema" } },
+				di.Tags{"migration": "schema"},
+				di.Before(di.Tags{"migration": "users"}),
+			),
+		)
+		require.NoError(t, err)
+		var migrations []Migration
+		require.NoError(t, c.Resolve(&migrations))
+		var result []string
+		for _, fn := range migrations {
+			result = append(result, fn())
+		}
+		require.Equal(t, []string{"schema", "users", "orders"}, result)
+	})
+
+	t.Run("iterate respects order", func(t *testing.T) {
+		conn1 := &net.TCPConn{}
+		conn2 := &net.TCPConn{}
+		c, err := di.New(
+			di.Provide(func() *net.TCPConn { return conn1 }, di.Tags{"conn": "1"}),
+			di.Provide(func() *net.TCPConn { return conn2 }, di.Tags{"conn": "2"}, di.Before(di.Tags{"conn": "1"})),
+		)
+		require.NoError(t, err)
+		var conns []*net.TCPConn
+		var all []di.Tags
+		err = c.Iterate(&conns, func(tags di.Tags, loader di.ValueFunc) error {
+			all = append(all, tags)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, []di.Tags{{"conn": "2"}, {"conn": "1"}}, all)
+	})
+
+	t.Run("cycle in order constraints cause error", func(t *testing.T) {
+		c, err := di.New(
+			di.Provide(http.NewServeMux, di.Tags{"name": "first"}, di.Before(di.Tags{"name": "second"})),
+			di.Provide(http.NewServeMux, di.Tags{"name": "second"}, di.Before(di.Tags{"name": "first"})),
+		)
+		require.NoError(t, err)
+		var muxs []*http.ServeMux
+		err = c.Resolve(&muxs)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "container_test.go:")
+		require.Contains(t, err.Error(), "[]*http.ServeMux: cycle detected in group order: *http.ServeMux[name:first] at ")
+	})
+}
+
+func TestContainer_MapGroups(t *testing.T) {
+	t.Run("resolve map keyed by name tag", func(t *testing.T) {
+		users := &http.ServeMux{}
+		orders := &http.ServeMux{}
+		c, err := di.New(
+			di.Provide(func() *http.ServeMux { return users }, di.Tags{"name": "users"}, di.As(new(http.Handler))),
+			di.Provide(func() *http.ServeMux { return orders }, di.Tags{"name": "orders"}, di.As(new(http.Handler))),
+			di.Provide(func() *http.ServeMux { return &http.ServeMux{} }, di.As(new(http.Handler))),
+		)
+		require.NoError(t, err)
+		var handlers map[string]http.Handler
+		require.NoError(t, c.Resolve(&handlers))
+		require.Len(t, handlers, 2)
+		require.Equal(t, fmt.Sprintf("%p", users), fmt.Sprintf("%p", handlers["users"]))
+		require.Equal(t, fmt.Sprintf("%p", orders), fmt.Sprintf("%p", handlers["orders"]))
+	})
+
+	t.Run("resolve map keyed by custom tag", func(t *testing.T) {
+		type Plugin func() string
+		c, err := di.New(
+			di.Provide(func() Plugin { return func() string { return "a" } }, di.Tags{"plugin": "a", "kind": "x"}),
+			di.Provide(func() Plugin { return func() string { return "b" } }, di.Tags{"plugin": "b"}),
+		)
+		require.NoError(t, err)
+		var plugins map[string]Plugin
+		require.NoError(t, c.Resolve(&plugins, di.Tags{"key": "plugin"}))
+		require.Len(t, plugins, 2)
+		require.Equal(t, "a", plugins["a"]())
+		require.Equal(t, "b", plugins["b"]())
+		require.NoError(t, c.Resolve(&plugins, di.Tags{"key": "plugin", "kind": "x"}))
+		require.Len(t, plugins, 1)
+	})
+
+	t.Run("inject map with key field tag", func(t *testing.T) {
+		type Params struct {
+			di.Inject
+			Servers map[string]*http.Server `di:"key=server"`
+		}
+		c, err := di.New(
+			di.Provide(func() *http.Server { return &http.Server{Addr: ":80"} }, di.Tags{"server": "http"}),
+			di.Provide(func() *http.Server { return &http.Server{Addr: ":443"} }, di.Tags{"server": "https"}),
+		)
+		require.NoError(t, err)
+		err = c.Invoke(func(params Params) {
+			require.Equal(t, ":80", params.Servers["http"].Addr)
+			require.Equal(t, ":443", params.Servers["https"].Addr)
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("duplicate keys cause error", func(t *testing.T) {
+		c, err := di.New(
+			di.Provide(http.NewServeMux, di.Tags{"name": "mux"}),
+			di.Provide(http.NewServeMux, di.Tags{"name": "mux"}),
+		)
+		require.NoError(t, err)
+		var muxs map[string]*http.ServeMux
+		err = c.Resolve(&muxs)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "container_test.go:")
+		require.Contains(t, err.Error(), "map[string]*http.ServeMux: duplicate key name:mux: *http.ServeMux[name:mux] at ")
+	})
+
+	t.Run("map without keyed members cause error", func(t *testing.T) {
+		c, err := di.New(
+			di.Provide(http.NewServeMux),
+		)
+		require.NoError(t, err)
+		var muxs map[string]*http.ServeMux
+		err = c.Resolve(&muxs)
+		require.Error(t, err)
+		require.True(t, errors.Is(err, di.ErrTypeNotExists))
+	})
+}
+
+func TestContainer_Flatten(t *testing.T) {
+	t.Run("flattened slice contributes group members", func(t *testing.T) {
+		type Handler func() string
+		c, err := di.New(
+			di.Provide(func() []Handler {
+				return []Handler{
+					func() string { return "admin" },
+					func() string { return "users" },
+				}
+			}, di.Flatten()),
+			di.Provide(func() Handler { return func() string { return "orders" } }),
+		)
+		require.NoError(t, err)
+		var handlers []Handler
+		require.NoError(t, c.Resolve(&handlers))
+		var result []string
+		for _, h := range handlers {
+			result = append(result, h())
+		}
+		require.Equal(t, []string{"admin", "users", "orders"}, result)
+		var handler Handler
+		require.NoError(t, c.Resolve(&handler))
+		require.Equal(t, "orders", handler())
+	})
+
+	t.Run("flattened interface members", func(t *testing.T) {
+		mux1 := &http.ServeMux{}
+		mux2 := &http.ServeMux{}
+		c, err := di.New(
+			di.Provide(func() []*http.ServeMux { return []*http.ServeMux{mux1, mux2} }, di.Flatten(), di.As(new(http.Handler))),
+		)
+		require.NoError(t, err)
+		var handlers []http.Handler
+		require.NoError(t, c.Resolve(&handlers))
+		require.Len(t, handlers, 2)
+		require.Equal(t, fmt.Sprintf("%p", mux1), fmt.Sprintf("%p", handlers[0]))
+		require.Equal(t, fmt.Sprintf("%p", mux2), fmt.Sprintf("%p", handlers[1]))
+		var handler http.Handler
+		err = c.Resolve(&handler)
+		require.Error(t, err)
+		require.True(t, errors.Is(err, di.ErrTypeNotExists))
+	})
+
+	t.Run("iterate over flattened members", func(t *testing.T) {
+		conn1 := &net.TCPConn{}
+		conn2 := &net.TCPConn{}
+		conn3 := &net.TCPConn{}
+		c, err := di.New(
+			di.Provide(func() *net.TCPConn { return conn1 }),
+			di.ProvideValue([]*net.TCPConn{conn2, conn3}, di.Flatten()),
+		)
+		require.NoError(t, err)
+		var conns []*net.TCPConn
+		var iterated []*net.TCPConn
+		err = c.Iterate(&conns, func(tags di.Tags, loader di.ValueFunc) error {
+			v, err := loader()
+			if err != nil {
+				return err
+			}
+			iterated = append(iterated, v.(*net.TCPConn))
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, []*net.TCPConn{conn1, conn2, conn3}, iterated)
+	})
+
+	t.Run("flatten not a slice cause error", func(t *testing.T) {
+		_, err := di.New(
+			di.Provide(http.NewServeMux, di.Flatten()),
+		)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "container_test.go:")
+		require.Contains(t, err.Error(), ": *http.ServeMux: only slices can be flattened")
+	})
+}
+
+func TestContainer_NamedGroups(t *testing.T) {
+	t.Run("resolve named group without interface registration", func(t *testing.T) {
+		mux := &http.ServeMux{}
+		file := &os.File{}
+		c, err := di.New(
+			di.Provide(func() *http.ServeMux { return mux }, di.Group("handlers")),
+			di.Provide(func() *os.File { return file }, di.Group("closers")),
+			di.Provide(func() *http.Server { return &http.Server{} }),
+		)
+		require.NoError(t, err)
+		var handlers []http.Handler
+		require.NoError(t, c.Resolve(&handlers, di.InGroup("handlers")))
+		require.Len(t, handlers, 1)
+		require.Equal(t, fmt.Sprintf("%p", mux), fmt.Sprintf("%p", handlers[0]))
+		var handler http.Handler
+		err = c.Resolve(&handler)
+		require.True(t, errors.Is(err, di.ErrTypeNotExists))
+	})
+
+	t.Run("inject named group with tags", func(t *testing.T) {
+		type Plugin interface{}
+		type Params struct {
+			di.Inject
+			Plugins []Plugin          `di:"di.group=plugins"`
+			ByName  map[string]Plugin `di:"di.group=plugins,key=plugin"`
+		}
+		c, err := di.New(
+			di.ProvideValue("auth", di.Group("plugins"), di.Tags{"plugin": "auth"}),
+			di.ProvideValue(42, di.Group("plugins"), di.Tags{"plugin": "answer"}),
+		)
+		require.NoError(t, err)
+		err = c.Invoke(func(params Params) {
+			require.Equal(t, []Plugin{"auth", 42}, params.Plugins)
+			require.Equal(t, map[string]Plugin{"auth": "auth", "answer": 42}, params.ByName)
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("group tag of members is not a group name", func(t *testing.T) {
+		c, err := di.New(
+			di.ProvideValue("first", di.Tags{"group": "strings"}),
+			di.ProvideValue("second", di.Tags{"group": "other"}),
+		)
+		require.NoError(t, err)
+		var strs []string
+		require.NoError(t, c.Resolve(&strs, di.Tags{"group": "strings"}))
+		require.Equal(t, []string{"first"}, strs)
+	})
+
+	t.Run("declared empty group resolved as empty slice", func(t *testing.T) {
+		c, err := di.New(
+			di.DeclareGroup("handlers"),
+		)
+		require.NoError(t, err)
+		var handlers []http.Handler
+		require.NoError(t, c.Resolve(&handlers, di.InGroup("handlers")))
+		require.NotNil(t, handlers)
+		require.Len(t, handlers, 0)
+	})
+
+	t.Run("not declared empty group cause error", func(t *testing.T) {
+		c, err := di.New()
+		require.NoError(t, err)
+		var handlers []http.Handler
+		err = c.Resolve(&handlers, di.InGroup("handlers"))
+		require.True(t, errors.Is(err, di.ErrTypeNotExists))
+	})
+
+	t.Run("empty auto group allowed by option", func(t *testing.T) {
+		type Params struct {
+			di.Inject
+			Handlers []http.Handler
+		}
+		c, err := di.New(
+			di.AllowEmptyGroups(),
+		)
+		require.NoError(t, err)
+		err = c.Invoke(func(params Params) {
+			require.NotNil(t, params.Handlers)
+			require.Len(t, params.Handlers, 0)
+		})
+		require.NoError(t, err)
+	})
+}
+
+type testRepository interface {
+	Get() string
+}
+
+type testRepositoryFunc func() string
+
+func (f testRepositoryFunc) Get() string { return f() }
+
+func TestContainer_Wrap(t *testing.T) {
+	t.Run("decorator replaces interface value with dependencies", func(t *testing.T) {
+		type Prefix string
+		c, err := di.New(
+			di.ProvideValue(Prefix("cached")),
+			di.Provide(func() testRepositoryFunc {
+				return func() string { return "value" }
+			}, di.As(new(testRepository))),
+			di.Wrap(func(orig testRepository, prefix Prefix) (testRepository, error) {
+				return testRepositoryFunc(func() string { return string(prefix) + " " + orig.Get() }), nil
+			}),
+		)
+		require.NoError(t, err)
+		var repo testRepository
+		require.NoError(t, c.Resolve(&repo))
+		require.Equal(t, "cached value", repo.Get())
+		var orig testRepositoryFunc
+		require.NoError(t, c.Resolve(&orig))
+		require.Equal(t, "value", orig.Get())
+	})
+
+	t.Run("decorators chained in registration order", func(t *testing.T) {
+		c, err := di.New(
+			di.ProvideValue("value"),
+		)
+		require.NoError(t, err)
+		require.NoError(t, c.Decorate(func(orig string) string { return "first(" + orig + ")" }))
+		require.NoError(t, c.Decorate(func(orig string) string { return "second(" + orig + ")" }))
+		var s string
+		require.NoError(t, c.Resolve(&s))
+		require.Equal(t, "second(first(value))", s)
+		// decorators applied once
+		require.NoError(t, c.Resolve(&s))
+		require.Equal(t, "second(first(value))", s)
+	})
+
+	t.Run("decorate tagged type", func(t *testing.T) {
+		c, err := di.New(
+			di.ProvideValue("first", di.Tags{"name": "first"}),
+			di.ProvideValue("second", di.Tags{"name": "second"}),
+			di.Wrap(func(orig string) string { return orig + " decorated" }, di.Tags{"name": "second"}),
+		)
+		require.NoError(t, err)
+		var strs []string
+		require.NoError(t, c.Resolve(&strs))
+		require.Equal(t, []string{"first", "second decorated"}, strs)
+	})
+
+	t.Run("decorator of parent applied to child types", func(t *testing.T) {
+		parent, err := di.New(
+			di.Wrap(func(orig string) string { return orig + " decorated" }),
+		)
+		require.NoError(t, err)
+		child, err := di.New(
+			di.ProvideValue("child"),
+		)
+		require.NoError(t, err)
+		require.NoError(t, child.AddParent(parent))
+		var s string
+		require.NoError(t, child.Resolve(&s))
+		require.Equal(t, "child decorated", s)
+	})
+
+	t.Run("decorator error", func(t *testing.T) {
+		c, err := di.New(
+			di.ProvideValue("value"),
+			di.Wrap(func(orig string) (string, error) { return "", errors.New("decorator error") }),
+		)
+		require.NoError(t, err)
+		var s string
+		err = c.Resolve(&s)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "container_test.go:")
+		require.Contains(t, err.Error(), ": string: decorator error")
+	})
+
+	t.Run("decorator with not existing dependency cause error", func(t *testing.T) {
+		c, err := di.New(
+			di.ProvideValue("value"),
+			di.Wrap(func(orig string, server *http.Server) string { return orig }),
+		)
+		require.NoError(t, err)
+		var s string
+		err = c.Resolve(&s)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "container_test.go:")
+		require.Contains(t, err.Error(), "decorator: type *http.Server not exists in the container")
+	})
+
+	t.Run("decorator depends on decorated type cause error", func(t *testing.T) {
+		c, err := di.New(
+			di.ProvideValue("value"),
+			di.Provide(func(s string) int { return len(s) }),
+			di.Wrap(func(orig string, i int) string { return orig }),
+		)
+		require.NoError(t, err)
+		var s string
+		err = c.Resolve(&s)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "cycle detected")
+	})
+
+	t.Run("invalid decorator signature cause error", func(t *testing.T) {
+		c, err := di.New()
+		require.NoError(t, err)
+		err = c.Decorate(func(orig string) int { return 0 })
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "container_test.go:")
+		require.Contains(t, err.Error(), ": invalid decorator signature, got func(string) int")
+		err = c.Decorate(nil)
+		require.Contains(t, err.Error(), ": invalid decorator signature, got nil")
+	})
+}
+
+type testLoggingHandler struct {
+	http.Handler
+}
+
+func TestContainer_DecorateAll(t *testing.T) {
+	t.Run("decorate every implementation of interface", func(t *testing.T) {
+		c, err := di.New(
+			di.Provide(http.NewServeMux, di.As(new(http.Handler)), di.Tags{"name": "first"}),
+			di.WrapAll(di.Implements(new(http.Handler)), func(h http.Handler) http.Handler {
+				return &testLoggingHandler{Handler: h}
+			}),
+		)
+		require.NoError(t, err)
+		// provided after decorator registration
+		require.NoError(t, c.Provide(http.NewServeMux, di.As(new(http.Handler)), di.Tags{"name": "second"}))
+		var handlers []http.Handler
+		require.NoError(t, c.Resolve(&handlers))
+		require.Len(t, handlers, 2)
+		for _, h := range handlers {
+			require.IsType(t, &testLoggingHandler{}, h)
+		}
+		// concrete type can't be replaced with handler
+		var mux *http.ServeMux
+		require.NoError(t, c.Resolve(&mux, di.Tags{"name": "first"}))
+	})
+
+	t.Run("decorate by type", func(t *testing.T) {
+		c, err := di.New(
+			di.ProvideValue(&http.Server{Addr: ":80"}),
+			di.ProvideValue(&http.Server{Addr: ":443"}, di.Tags{"tls": "true"}),
+		)
+		require.NoError(t, err)
+		err = c.DecorateAll(di.OfType(new(*http.Server)), func(server *http.Server) *http.Server {
+			return &http.Server{Addr: "decorated" + server.Addr}
+		})
+		require.NoError(t, err)
+		var servers []*http.Server
+		require.NoError(t, c.Resolve(&servers))
+		require.Equal(t, "decorated:80", servers[0].Addr)
+		require.Equal(t, "decorated:443", servers[1].Addr)
+	})
+
+	t.Run("decorate by tags in child container", func(t *testing.T) {
+		parent, err := di.New(
+			di.WrapAll(di.Tags{"layer": "repository"}, func(h testRepository) testRepository {
+				return testRepositoryFunc(func() string { return "cached " + h.Get() })
+			}),
+		)
+		require.NoError(t, err)
+		child, err := di.New(
+			di.ProvideValue(testRepositoryFunc(func() string { return "users" }), di.As(new(testRepository)), di.Tags{"layer": "repository"}),
+		)
+		require.NoError(t, err)
+		require.NoError(t, child.AddParent(parent))
+		var repo testRepository
+		require.NoError(t, child.Resolve(&repo))
+		require.Equal(t, "cached users", repo.Get())
+	})
+
+	t.Run("validation decorator", func(t *testing.T) {
+		c, err := di.New(
+			di.ProvideValue(&http.Server{}),
+			di.WrapAll(di.OfType(new(*http.Server)), func(server *http.Server) error {
+				if server.Addr == "" {
+					return errors.New("server address required")
+				}
+				return nil
+			}),
+		)
+		require.NoError(t, err)
+		var server *http.Server
+		err = c.Resolve(&server)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "container_test.go:")
+		require.Contains(t, err.Error(), ": *http.Server: server address required")
+	})
+
+	t.Run("invalid matcher cause error", func(t *testing.T) {
+		c, err := di.New()
+		require.NoError(t, err)
+		err = c.DecorateAll(di.Implements(new(http.Server)), func(h http.Handler) http.Handler { return h })
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "container_test.go:")
+		require.Contains(t, err.Error(), ": *http.Server: not a pointer to interface")
+		err = c.DecorateAll(nil, func(h http.Handler) http.Handler { return h })
+		require.Contains(t, err.Error(), ": invalid matcher, got nil")
+	})
+}
+
+type testStorage interface {
+	Load(key string, defaults ...string) (string, error)
+}
+
+type testStorageMap map[string]string
+
+func (m testStorageMap) Load(key string, defaults ...string) (string, error) {
+	if v, ok := m[key]; ok {
+		return v, nil
+	}
+	if len(defaults) > 0 {
+		return defaults[0], nil
+	}
+	return "", errors.New("not found")
+}
+
+// testStorageProxy is written as di-proxy generates it.
+type testStorageProxy di.ProxyHandler
+
+func (p testStorageProxy) Load(a0 string, a1 ...string) (string, error) {
+	out := p("Load", []reflect.Value{reflect.ValueOf(&a0).Elem(), reflect.ValueOf(&a1).Elem()})
+	r0, _ := out[0].Interface().(string)
+	r1, _ := out[1].Interface().(error)
+	return r0, r1
+}
+
+func init() {
+	di.RegisterProxy(new(testStorage), func(handler di.ProxyHandler) interface{} {
+		return testStorageProxy(handler)
+	})
+}
+
+func TestContainer_Intercept(t *testing.T) {
+	t.Run("interceptors are called in registration order", func(t *testing.T) {
+		var calls []string
+		trace := func(name string) di.Interceptor {
+			return func(call di.Call) []reflect.Value {
+				calls = append(calls, fmt.Sprintf("%s %s.%s%v", name, call.Interface.Name(), call.Method, call.Args[0]))
+				return call.Proceed()
+			}
+		}
+		c, err := di.New(
+			di.ProvideValue(testStorageMap{"key": "value"}, di.As(new(testStorage))),
+			di.Intercept(new(testStorage), trace("first"), trace("second")),
+		)
+		require.NoError(t, err)
+		var storage testStorage
+		require.NoError(t, c.Resolve(&storage))
+		v, err := storage.Load("key")
+		require.NoError(t, err)
+		require.Equal(t, "value", v)
+		require.Equal(t, []string{"first testStorage.Loadkey", "second testStorage.Loadkey"}, calls)
+	})
+
+	t.Run("interceptor changes arguments and results", func(t *testing.T) {
+		c, err := di.New(
+			di.ProvideValue(testStorageMap{}, di.As(new(testStorage))),
+		)
+		require.NoError(t, err)
+		err = c.Intercept(new(testStorage), func(call di.Call) []reflect.Value {
+			call.Args[1] = reflect.ValueOf([]string{"default"})
+			out := call.Proceed()
+			out[0] = reflect.ValueOf(strings.ToUpper(out[0].String()))
+			return out
+		})
+		require.NoError(t, err)
+		var storage testStorage
+		require.NoError(t, c.Resolve(&storage))
+		v, err := storage.Load("unknown")
+		require.NoError(t, err)
+		require.Equal(t, "DEFAULT", v)
+	})
+
+	t.Run("interceptor skips original method", func(t *testing.T) {
+		c, err := di.New(
+			di.ProvideValue(testStorageMap{"key": "value"}, di.As(new(testStorage))),
+			di.Intercept(new(testStorage), func(call di.Call) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(""), reflect.ValueOf(errors.New("unavailable"))}
+			}),
+		)
+		require.NoError(t, err)
+		var storage testStorage
+		require.NoError(t, c.Resolve(&storage))
+		_, err = storage.Load("key")
+		require.EqualError(t, err, "unavailable")
+	})
+
+	t.Run("concrete type is not intercepted", func(t *testing.T) {
+		c, err := di.New(
+			di.ProvideValue(testStorageMap{"key": "value"}, di.As(new(testStorage))),
+			di.Intercept(new(testStorage), func(call di.Call) []reflect.Value {
+				panic("must not be called")
+			}),
+		)
+		require.NoError(t, err)
+		var storage testStorageMap
+		require.NoError(t, c.Resolve(&storage))
+		v, err := storage.Load("key")
+		require.NoError(t, err)
+		require.Equal(t, "value", v)
+	})
+
+	t.Run("interface without proxy cause error", func(t *testing.T) {
+		_, err := di.New(
+			di.Intercept(new(testRepository), func(call di.Call) []reflect.Value {
+				return call.Proceed()
+			}),
+		)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "container_test.go:")
+		require.Contains(t, err.Error(), ": no proxy registered for di_test.testRepository, generate it with di-proxy")
+	})
+
+	t.Run("invalid interface or interceptor cause error", func(t *testing.T) {
+		c, err := di.New()
+		require.NoError(t, err)
+		err = c.Intercept(new(testStorageMap))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), ": *di_test.testStorageMap: not a pointer to interface")
+		err = c.Intercept(new(testStorage), nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), ": invalid interceptor, got nil")
+	})
+}
+
+func TestContainer_Module(t *testing.T) {
+	type Repository struct{ Name string }
+	type Service struct{ Repository *Repository }
+	newService := func(repository *Repository) *Service {
+		return &Service{Repository: repository}
+	}
+
+	t.Run("private type injectable inside module only", func(t *testing.T) {
+		var invoked *Repository
+		c, err := di.New(
+			di.Module("billing",
+				di.ProvideValue(&Repository{Name: "invoices"}, di.Private()),
+				di.Provide(newService),
+				di.Invoke(func(repository *Repository) { invoked = repository }),
+			),
+		)
+		require.NoError(t, err)
+		require.Equal(t, "invoices", invoked.Name)
+		var service *Service
+		require.NoError(t, c.Resolve(&service))
+		require.Equal(t, "invoices", service.Repository.Name)
+		var repository *Repository
+		err = c.Resolve(&repository)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "container_test.go:")
+		require.Contains(t, err.Error(), ": type *di_test.Repository is private in module billing")
+		has, err := c.Has(&repository)
+		require.NoError(t, err)
+		require.False(t, has)
+	})
+
+	t.Run("private type not injectable in another module", func(t *testing.T) {
+		c, err := di.New(
+			di.Module("billing",
+				di.ProvideValue(&Repository{}, di.Private()),
+			),
+			di.Module("reports",
+				di.Provide(newService),
+			),
+		)
+		require.NoError(t, err)
+		var service *Service
+		err = c.Resolve(&service)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), ": *di_test.Service (module reports): type *di_test.Repository is private in module billing")
+	})
+
+	t.Run("private type is not a member of groups outside module", func(t *testing.T) {
+		c, err := di.New(
+			di.Module("billing",
+				di.ProvideValue(&Repository{Name: "private"}, di.Private()),
+			),
+			di.ProvideValue(&Repository{Name: "public"}),
+		)
+		require.NoError(t, err)
+		var repositories []*Repository
+		require.NoError(t, c.Resolve(&repositories))
+		require.Len(t, repositories, 1)
+		require.Equal(t, "public", repositories[0].Name)
+	})
+
+	t.Run("nested module has its own private types", func(t *testing.T) {
+		_, err := di.New(
+			di.Module("billing",
+				di.Module("storage",
+					di.ProvideValue(&Repository{}, di.Private()),
+				),
+				di.Invoke(func(repository *Repository) {}),
+			),
+		)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "type *di_test.Repository is private in module storage")
+	})
+
+	t.Run("missing required module reported up front", func(t *testing.T) {
+		invoked := false
+		_, err := di.New(
+			di.Module("billing",
+				di.Import("db"),
+				di.Invoke(func() { invoked = true }),
+			),
+		)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "container_test.go:")
+		require.Contains(t, err.Error(), ": module billing requires module db that is not registered")
+		require.False(t, invoked)
+	})
+
+	t.Run("required module registered later in options", func(t *testing.T) {
+		_, err := di.New(
+			di.Module("billing", di.Import("db")),
+			di.Module("db"),
+			di.Import("billing"),
+		)
+		require.NoError(t, err)
+		_, err = di.New(di.Import("billing"))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), ": container requires module billing that is not registered")
+	})
+
+	t.Run("required module in parent container", func(t *testing.T) {
+		parent, err := di.New(di.Module("db"))
+		require.NoError(t, err)
+		child, err := di.New()
+		require.NoError(t, err)
+		require.NoError(t, child.AddParent(parent))
+		require.NoError(t, child.Apply(di.Module("billing", di.Import("db"))))
+	})
+
+	t.Run("duplicate module cause error", func(t *testing.T) {
+		_, err := di.New(
+			di.Module("billing"),
+			di.Module("billing"),
+		)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), ": module billing already registered at ")
+	})
+
+	t.Run("private type outside module cause error", func(t *testing.T) {
+		_, err := di.New(
+			di.ProvideValue(&Repository{}, di.Private()),
+		)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), ": *di_test.Repository: only types provided inside module can be private")
+	})
+
+	t.Run("error contains module of provided type", func(t *testing.T) {
+		c, err := di.New(
+			di.Module("billing",
+				di.Provide(newService),
+			),
+		)
+		require.NoError(t, err)
+		var service *Service
+		err = c.Resolve(&service)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), ": *di_test.Service (module billing): type *di_test.Repository not exists in the container")
+	})
+}
+
+func TestContainer_Conditions(t *testing.T) {
+	t.Run("if applies options when condition is true", func(t *testing.T) {
+		c, err := di.New(
+			di.If(true, di.ProvideValue(&http.Server{Addr: ":80"})),
+			di.If(false, di.ProvideValue(&http.Server{Addr: ":443"})),
+		)
+		require.NoError(t, err)
+		var server *http.Server
+		require.NoError(t, c.Resolve(&server))
+		require.Equal(t, ":80", server.Addr)
+	})
+
+	t.Run("if env compares environment variable", func(t *testing.T) {
+		t.Setenv("DI_TEST_CONNECTION", "udp")
+		c, err := di.New(
+			di.IfEnv("DI_TEST_CONNECTION", "tcp", di.Provide(func() *net.TCPConn { return &net.TCPConn{} }, di.As(new(net.Conn)))),
+			di.IfEnv("DI_TEST_CONNECTION", "udp", di.Provide(func() *net.UDPConn { return &net.UDPConn{} }, di.As(new(net.Conn)))),
+		)
+		require.NoError(t, err)
+		var conn net.Conn
+		require.NoError(t, c.Resolve(&conn))
+		require.IsType(t, &net.UDPConn{}, conn)
+	})
+
+	t.Run("when resolves condition dependencies", func(t *testing.T) {
+		type Config struct{ Debug bool }
+		var invoked bool
+		c, err := di.New(
+			di.When(func(config *Config) bool {
+				return config.Debug
+			},
+				di.ProvideValue(&http.Server{Addr: ":6060"}),
+				di.Invoke(func(server *http.Server) { invoked = true }),
+			),
+			di.ProvideValue(&Config{Debug: true}),
+		)
+		require.NoError(t, err)
+		require.True(t, invoked)
+		var server *http.Server
+		require.NoError(t, c.Resolve(&server))
+		require.Equal(t, ":6060", server.Addr)
+	})
+
+	t.Run("nested conditions inside module", func(t *testing.T) {
+		c, err := di.New(
+			di.Module("debug",
+				di.If(true,
+					di.If(true, di.ProvideValue(&http.Server{}, di.Private())),
+					di.Provide(func(server *http.Server) *http.ServeMux { return &http.ServeMux{} }),
+				),
+			),
+		)
+		require.NoError(t, err)
+		var mux *http.ServeMux
+		require.NoError(t, c.Resolve(&mux))
+		has, err := c.Has(new(*http.Server))
+		require.NoError(t, err)
+		require.False(t, has)
+	})
+
+	t.Run("when condition is evaluated on the first lookup", func(t *testing.T) {
+		type Config struct{ Debug bool }
+		var built bool
+		c, err := di.New(
+			di.Provide(func() *Config {
+				built = true
+				return &Config{Debug: true}
+			}),
+			di.When(func(config *Config) bool {
+				return config.Debug
+			},
+				di.ProvideValue(&http.Server{Addr: ":6060"}),
+				di.Wrap(func(config *Config) *Config { return &Config{} }),
+			),
+		)
+		require.NoError(t, err)
+		require.False(t, built)
+		var server *http.Server
+		require.NoError(t, c.Resolve(&server))
+		require.True(t, built)
+		require.Equal(t, ":6060", server.Addr)
+		// dependencies of the condition are not decorated by its options
+		var config *Config
+		require.NoError(t, c.Resolve(&config))
+		require.True(t, config.Debug)
+		c, err = di.New(
+			di.ProvideValue(&Config{Debug: true}),
+			di.When(func(config *Config) bool {
+				return config.Debug
+			}, di.Wrap(func(config *Config) *Config { return &Config{} })),
+		)
+		require.NoError(t, err)
+		require.NoError(t, c.Resolve(&config))
+		require.True(t, config.Debug)
+	})
+
+	t.Run("skipped definitions stay registered but disabled", func(t *testing.T) {
+		type Config struct{ Debug bool }
+		c, err := di.New(
+			di.ProvideValue(&Config{}),
+			di.ProvideValue(&http.Client{}),
+			di.When(func(config *Config) bool {
+				return config.Debug
+			},
+				di.ProvideValue(&http.Server{Addr: ":6060"}),
+				di.Wrap(func(client *http.Client) *http.Client { return &http.Client{Timeout: time.Second} }),
+			),
+			di.If(false, di.ProvideValue(&http.ServeMux{})),
+		)
+		require.NoError(t, err)
+		has, err := c.Has(new(*http.Server))
+		require.NoError(t, err)
+		require.False(t, has)
+		var client *http.Client
+		require.NoError(t, c.Resolve(&client))
+		require.Zero(t, client.Timeout)
+		var server *http.Server
+		err = c.Resolve(&server)
+		require.Error(t, err)
+		require.Regexp(t, `type \*http.Server not exists in the container, did you mean \*http.Server at .*container_test.go:\d+, disabled by condition at .*container_test.go:\d+\?`, err.Error())
+		unused := c.Unused()
+		require.Len(t, unused, 2)
+		require.Regexp(t, `^\*http.Server at .*container_test.go:\d+, disabled by condition at .*container_test.go:\d+$`, unused[0])
+		require.Regexp(t, `^\*http.ServeMux at .*container_test.go:\d+, disabled by condition at .*container_test.go:\d+$`, unused[1])
+	})
+
+	t.Run("when condition depends on its own options", func(t *testing.T) {
+		c, err := di.New(
+			di.When(func(server *http.Server) bool { return true },
+				di.ProvideValue(&http.Server{}),
+			),
+		)
+		require.NoError(t, err)
+		var server *http.Server
+		err = c.Resolve(&server)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), ": cycle detected")
+	})
+
+	t.Run("when condition errors", func(t *testing.T) {
+		_, err := di.New(
+			di.When(func(server *http.Server) bool { return true }, di.Invoke(func() {})),
+		)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "container_test.go:")
+		require.Contains(t, err.Error(), ": type *http.Server not exists in the container")
+		_, err = di.New(di.When(func() {}))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), ": invalid condition signature, got func()")
+		_, err = di.New(di.When(nil))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), ": invalid condition signature, got nil")
+	})
+
+	t.Run("error of conditional option", func(t *testing.T) {
+		_, err := di.New(
+			di.If(true, di.Provide(nil)),
+		)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), ": invalid constructor signature, got nil")
+	})
+}
+
+func TestContainer_ProvideConfig(t *testing.T) {
+	type Database struct {
+		DSN string `env:"DSN" flag:"dsn" json:"dsn" xml:"dsn" required:"true"`
+	}
+	type Config struct {
+		Addr     string        `env:"ADDR" flag:"addr" json:"addr" xml:"addr" default:":8080"`
+		Timeout  time.Duration `env:"TIMEOUT" default:"5s"`
+		Debug    bool          `env:"DEBUG"`
+		Hosts    []string      `env:"HOSTS"`
+		Database Database      `env:"DB_" json:"database" xml:"database" provide:"true"`
+	}
+
+	t.Run("fields are set from defaults and environment", func(t *testing.T) {
+		t.Setenv("APP_DEBUG", "true")
+		t.Setenv("APP_HOSTS", "a, b")
+		t.Setenv("APP_DB_DSN", "postgres://localhost")
+		c, err := di.New(
+			di.ProvideConfig(&Config{}, di.FromEnv("APP_")),
+		)
 		require.NoError(t, err)
-		require.NotNil(t, parent)
-		child, err := di.New()
+		var config *Config
+		require.NoError(t, c.Resolve(&config))
+		require.Equal(t, ":8080", config.Addr)
+		require.Equal(t, 5*time.Second, config.Timeout)
+		require.True(t, config.Debug)
+		require.Equal(t, []string{"a", "b"}, config.Hosts)
+		var database *Database
+		require.NoError(t, c.Resolve(&database))
+		require.Equal(t, "postgres://localhost", database.DSN)
+		require.Same(t, &config.Database, database)
+	})
+
+	t.Run("sources override each other in order", func(t *testing.T) {
+		dir := t.TempDir()
+		files := map[string]string{
+			"config.json": `{"addr": ":1", "database": {"dsn": "json"}}`,
+			"config.xml":  `<config><addr>:2</addr><database><dsn>xml</dsn></database></config>`,
+		}
+		for name, content := range files {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+		}
+		sources := map[string]di.ConfigSource{
+			"json": di.FromFile(filepath.Join(dir, "config.json")),
+			"xml":  di.FromFileWith(filepath.Join(dir, "config.xml"), xml.Unmarshal),
+		}
+		addrs := map[string]string{"json": ":1", "xml": ":2"}
+		for format, source := range sources {
+			c, err := di.New(
+				di.ProvideConfig(&Config{}, source),
+			)
+			require.NoError(t, err)
+			var config *Config
+			require.NoError(t, c.Resolve(&config))
+			require.Equal(t, addrs[format], config.Addr)
+			require.Equal(t, format, config.Database.DSN)
+		}
+		_, err := di.New(
+			di.ProvideConfig(&Config{}, di.FromFile(filepath.Join(dir, "config.xml"))),
+		)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), `config.xml: unsupported config file format ".xml", use di.FromFileWith() with its decoder`)
+		t.Setenv("APP_ADDR", ":4")
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		flags.String("addr", "", "")
+		flags.String("dsn", "", "")
+		require.NoError(t, flags.Parse([]string{"-dsn", "flag"}))
+		c, err := di.New(
+			di.ProvideConfig(&Config{},
+				di.FromFile(filepath.Join(dir, "config.json")),
+				di.FromEnv("APP_"),
+				di.FromFlags(flags),
+			),
+		)
 		require.NoError(t, err)
-		require.NotNil(t, child)
+		var config *Config
+		require.NoError(t, c.Resolve(&config))
+		require.Equal(t, ":4", config.Addr)
+		require.Equal(t, "flag", config.Database.DSN)
+	})
 
-		require.NoError(t, parent.AddParent(papaw))
-		require.NoError(t, child.AddParent(parent))
+	t.Run("required field cause error", func(t *testing.T) {
+		_, err := di.New(
+			di.ProvideConfig(&Config{}),
+		)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "container_test.go:")
+		require.Contains(t, err.Error(), ": *di_test.Config.Database.DSN: required field is not set")
+	})
 
-		conn1 := &net.TCPConn{}
-		require.NoError(t, papaw.Provide(func() *net.TCPConn { return conn1 }))
+	t.Run("explicit zero value of required field", func(t *testing.T) {
+		type Limits struct {
+			Port  int    `env:"PORT" required:"true"`
+			Debug bool   `json:"debug" xml:"debug" required:"true"`
+			Name  string `flag:"name" required:"true"`
+		}
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"debug": false}`), 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "config.xml"), []byte(`<limits><debug>false</debug></limits>`), 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "empty.json"), []byte(`{}`), 0600))
+		t.Setenv("APP_PORT", "0")
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		flags.String("name", "default", "")
+		require.NoError(t, flags.Parse([]string{"-name", ""}))
+		for _, file := range []di.ConfigSource{
+			di.FromFile(filepath.Join(dir, "config.json")),
+			di.FromFileWith(filepath.Join(dir, "config.xml"), xml.Unmarshal),
+		} {
+			c, err := di.New(
+				di.ProvideConfig(&Limits{}, file, di.FromEnv("APP_"), di.FromFlags(flags)),
+			)
+			require.NoError(t, err)
+			var limits *Limits
+			require.NoError(t, c.Resolve(&limits))
+			require.Equal(t, Limits{}, *limits)
+		}
+		_, err := di.New(
+			di.ProvideConfig(&Limits{}, di.FromFile(filepath.Join(dir, "empty.json")), di.FromEnv("APP_"), di.FromFlags(flags)),
+		)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), ": *di_test.Limits.Debug: required field is not set")
+	})
 
-		var conn *net.TCPConn
-		require.NoError(t, child.Resolve(&conn))
-		require.Equal(t, fmt.Sprintf("%p", conn1), fmt.Sprintf("%p", conn))
+	t.Run("invalid value cause error", func(t *testing.T) {
+		t.Setenv("APP_TIMEOUT", "soon")
+		_, err := di.New(
+			di.ProvideConfig(&Config{Database: Database{DSN: "dsn"}}, di.FromEnv("APP_")),
+		)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), `: *di_test.Config.Timeout: env APP_TIMEOUT: time: invalid duration "soon"`)
 	})
 
-	t.Run("resolve multiple type instances across ancestors", func(t *testing.T) {
-		papaw, err := di.New()
+	t.Run("invalid config or source cause error", func(t *testing.T) {
+		_, err := di.New(di.ProvideConfig(Config{}))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), ": config must be a pointer to struct, got di_test.Config")
+		_, err = di.New(di.ProvideConfig(&Config{}, di.FromFile("config.ini")))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "config.ini")
+	})
+}
+
+type testAddresses struct {
+	Addr        string
+	MetricsAddr string
+}
+
+func newTestAddresses(addr string, metricsAddr string) *testAddresses {
+	return &testAddresses{Addr: addr, MetricsAddr: metricsAddr}
+}
+
+func newTestAddressesUnnamed(addr string, metricsAddr string) *testAddresses {
+	return &testAddresses{Addr: addr, MetricsAddr: metricsAddr}
+}
+
+func init() {
+	di.ParamNames(newTestAddresses, "addr", "metricsAddr")
+}
+
+func TestContainer_ParamNames(t *testing.T) {
+	t.Run("ambiguous parameter resolved by name", func(t *testing.T) {
+		c, err := di.New(
+			di.ProvideValue(":8080", di.WithName("addr")),
+			di.ProvideValue(":9090", di.WithName("metricsAddr")),
+			di.Provide(newTestAddresses),
+		)
 		require.NoError(t, err)
-		require.NotNil(t, papaw)
+		var addresses *testAddresses
+		require.NoError(t, c.Resolve(&addresses))
+		require.Equal(t, ":8080", addresses.Addr)
+		require.Equal(t, ":9090", addresses.MetricsAddr)
+	})
+
+	t.Run("invocation parameters resolved by name", func(t *testing.T) {
+		invoke := func(addr string, port int) {}
+		di.ParamNames(invoke, "addr", "port")
+		c, err := di.New(
+			di.ProvideValue(":8080", di.WithName("addr")),
+			di.ProvideValue(":9090", di.WithName("metricsAddr")),
+			di.ProvideValue(80),
+		)
+		require.NoError(t, err)
+		require.NoError(t, c.Invoke(invoke))
+	})
+
+	t.Run("function without names is ambiguous", func(t *testing.T) {
+		c, err := di.New(
+			di.ProvideValue(":8080", di.WithName("addr")),
+			di.ProvideValue(":9090", di.WithName("metricsAddr")),
+			di.Provide(newTestAddressesUnnamed),
+		)
+		require.NoError(t, err)
+		var addresses *testAddresses
+		err = c.Resolve(&addresses)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), ": multiple definitions of string, maybe you need to use group type: []string")
+	})
+
+	t.Run("invalid parameter names panic", func(t *testing.T) {
+		require.PanicsWithValue(t, "function expected, got string", func() {
+			di.ParamNames("func")
+		})
+		require.Panics(t, func() {
+			di.ParamNames(newTestAddressesUnnamed, "addr")
+		})
+	})
+}
+
+func TestContainer_Strict(t *testing.T) {
+	t.Run("untagged duplicate fails on provide", func(t *testing.T) {
+		c, err := di.New(
+			di.Strict(),
+			di.Provide(func() *http.Server { return &http.Server{} }),
+		)
+		require.NoError(t, err)
+		err = c.Provide(func() *http.Server { return &http.Server{} })
+		require.Error(t, err)
+		require.Regexp(t, `: \*http.Server: multiple definitions, already provided at .*container_test.go:\d+$`, err.Error())
+	})
+
+	t.Run("option fails on duplicate", func(t *testing.T) {
+		_, err := di.New(
+			di.Strict(),
+			di.ProvideValue(":8080"),
+			di.ProvideValue(":9090"),
+		)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "string: multiple definitions, already provided at ")
+	})
+
+	t.Run("tagged, primary and grouped definitions are allowed", func(t *testing.T) {
+		c, err := di.New(
+			di.Strict(),
+			di.Provide(func() *http.Server { return &http.Server{} }),
+			di.Provide(func() *http.Server { return &http.Server{} }, di.Tags{"name": "admin"}),
+			di.Provide(func() *http.Server { return &http.Server{} }, di.Primary()),
+			di.Provide(func() *http.Server { return &http.Server{} }, di.Group("servers")),
+			di.Provide(func() *http.ServeMux { return &http.ServeMux{} }, di.As(new(http.Handler))),
+			di.Provide(func() *http.ServeMux { return &http.ServeMux{} }, di.As(new(http.Handler)), di.Tags{"name": "admin"}),
+		)
+		require.NoError(t, err)
+		var handlers []http.Handler
+		require.NoError(t, c.Resolve(&handlers))
+		require.Len(t, handlers, 2)
+	})
+
+	t.Run("duplicates are allowed without strict mode", func(t *testing.T) {
+		_, err := di.New(
+			di.ProvideValue(":8080"),
+			di.ProvideValue(":9090"),
+		)
+		require.NoError(t, err)
+	})
+
+	t.Run("private definitions of different modules are allowed", func(t *testing.T) {
+		_, err := di.New(
+			di.Strict(),
+			di.Module("http", di.ProvideValue(":8080", di.Private())),
+			di.Module("metrics", di.ProvideValue(":9090", di.Private())),
+		)
+		require.NoError(t, err)
+	})
+}
+
+func TestContainer_Unused(t *testing.T) {
+	t.Run("reports providers that were never requested", func(t *testing.T) {
+		c, err := di.New(
+			di.Provide(func(mux *http.ServeMux) *http.Server { return &http.Server{Handler: mux} }),
+			di.Provide(func() *http.ServeMux { return &http.ServeMux{} }),
+			di.Provide(func() *http.Client { return &http.Client{} }),
+			di.ProvideValue(":8080"),
+		)
+		require.NoError(t, err)
+		require.Len(t, c.Unused(), 4)
+		var server *http.Server
+		require.NoError(t, c.Resolve(&server))
+		unused := c.Unused()
+		require.Len(t, unused, 2)
+		require.Regexp(t, `^string at .*container_test.go:\d+$`, unused[0])
+		require.Regexp(t, `^\*http.Client at .*container_test.go:\d+$`, unused[1])
+		require.NoError(t, c.Invoke(func(client *http.Client, addr string) {}))
+		require.Empty(t, c.Unused())
+	})
+
+	t.Run("interface request uses its provider", func(t *testing.T) {
+		c, err := di.New(
+			di.Provide(func() *http.ServeMux { return &http.ServeMux{} }, di.As(new(http.Handler))),
+		)
+		require.NoError(t, err)
+		var handler http.Handler
+		require.NoError(t, c.Resolve(&handler))
+		require.Empty(t, c.Unused())
+	})
+
+	t.Run("has does not use provider", func(t *testing.T) {
+		c, err := di.New(
+			di.Provide(func() *http.ServeMux { return &http.ServeMux{} }),
+		)
+		require.NoError(t, err)
+		has, err := c.Has(new(*http.ServeMux))
+		require.NoError(t, err)
+		require.True(t, has)
+		require.Len(t, c.Unused(), 1)
+	})
+}
+
+func TestContainer_Seal(t *testing.T) {
+	t.Run("registrations are rejected", func(t *testing.T) {
+		c, err := di.New(
+			di.Provide(func() *http.Server { return &http.Server{} }),
+		)
+		require.NoError(t, err)
+		c.Seal()
+		err = c.Provide(func() *http.ServeMux { return &http.ServeMux{} })
+		require.Regexp(t, `: provide is rejected, container is sealed at .*container_test.go:\d+$`, err.Error())
+		var sealed *di.SealedError
+		require.True(t, errors.As(err, &sealed))
+		require.Equal(t, "provide", sealed.Op)
+		require.Contains(t, sealed.Frame, "container_test.go:")
+		require.True(t, errors.As(c.ProvideValue(":8080"), &sealed))
+		require.True(t, errors.As(c.Apply(di.Provide(func() *http.ServeMux { return &http.ServeMux{} })), &sealed))
+		require.Equal(t, "apply", sealed.Op)
+		require.True(t, errors.As(c.Decorate(func(server *http.Server) *http.Server { return server }), &sealed))
+		require.True(t, errors.As(c.DecorateAll(di.OfType(new(*http.Server)), func(server *http.Server) *http.Server { return server }), &sealed))
 		parent, err := di.New()
 		require.NoError(t, err)
-		require.NotNil(t, parent)
-		child, err := di.New()
+		require.True(t, errors.As(c.AddParent(parent), &sealed))
+		require.Equal(t, "add parent", sealed.Op)
+		has, err := c.Has(new(*http.ServeMux))
 		require.NoError(t, err)
-		require.NotNil(t, child)
+		require.False(t, has)
+	})
 
-		require.NoError(t, parent.AddParent(papaw))
-		require.NoError(t, child.AddParent(parent))
+	t.Run("types are resolved", func(t *testing.T) {
+		c, err := di.New(
+			di.Provide(func(mux *http.ServeMux) *http.Server { return &http.Server{Handler: mux} }),
+			di.Provide(func() *http.ServeMux { return &http.ServeMux{} }),
+		)
+		require.NoError(t, err)
+		c.Seal()
+		var server1, server2 *http.Server
+		require.NoError(t, c.Resolve(&server1))
+		require.NoError(t, c.Resolve(&server2))
+		require.Equal(t, server1, server2)
+		require.NoError(t, c.Invalidate(new(*http.ServeMux)))
+		require.NoError(t, c.Resolve(&server2))
+		require.NotSame(t, server1, server2)
+	})
 
-		conn1 := &net.TCPConn{}
-		conn2 := &net.TCPConn{}
-		conn3 := &net.TCPConn{}
-		require.NoError(t, papaw.Provide(func() *net.TCPConn { return conn1 }))
-		require.NoError(t, parent.Provide(func() *net.TCPConn { return conn2 }))
-		require.NoError(t, child.Provide(func() *net.TCPConn { return conn3 }))
+	t.Run("types are resolved concurrently", func(t *testing.T) {
+		c, err := di.New(
+			di.Provide(func(mux *http.ServeMux) *http.Server { return &http.Server{Handler: mux} }),
+			di.Provide(func() *http.ServeMux { return &http.ServeMux{} }),
+			di.Sealed(),
+		)
+		require.NoError(t, err)
+		errs := make(chan error)
+		for i := 0; i < 10; i++ {
+			go func() {
+				var server *http.Server
+				errs <- c.Resolve(&server)
+			}()
+		}
+		for i := 0; i < 10; i++ {
+			require.NoError(t, <-errs)
+		}
+	})
 
-		var conns []*net.TCPConn
-		require.NoError(t, child.Resolve(&conns))
-		require.Len(t, conns, 3)
-		require.Equal(t, fmt.Sprintf("%p", conn1), fmt.Sprintf("%p", conns[0]))
-		require.Equal(t, fmt.Sprintf("%p", conn2), fmt.Sprintf("%p", conns[1]))
-		require.Equal(t, fmt.Sprintf("%p", conn3), fmt.Sprintf("%p", conns[2]))
+	t.Run("errors are reported on each resolve", func(t *testing.T) {
+		c, err := di.New(
+			di.Provide(func(addr string) *http.Server { return &http.Server{Addr: addr} }),
+		)
+		require.NoError(t, err)
+		c.Seal()
+		require.True(t, errors.Is(c.Resolve(new(*http.Server)), di.ErrTypeNotExists))
+		require.True(t, errors.Is(c.Resolve(new(*http.Server)), di.ErrTypeNotExists))
 	})
 
-	t.Run("add parent errors", func(t *testing.T) {
+	t.Run("sealed option seals after options are applied", func(t *testing.T) {
+		var server *http.Server
+		c, err := di.New(
+			di.Sealed(),
+			di.Provide(func() *http.Server { return &http.Server{} }),
+			di.Resolve(&server),
+		)
+		require.NoError(t, err)
+		require.NotNil(t, server)
+		var sealed *di.SealedError
+		require.True(t, errors.As(c.Provide(func() *http.ServeMux { return &http.ServeMux{} }), &sealed))
+	})
+
+	t.Run("child of sealed container accepts registrations", func(t *testing.T) {
+		parent, err := di.New(di.Sealed())
+		require.NoError(t, err)
+		c, err := di.New()
+		require.NoError(t, err)
+		require.NoError(t, c.AddParent(parent))
+		require.NoError(t, c.Provide(func() *http.Server { return &http.Server{} }))
+	})
+}
+
+func TestContainer_ResolverOnly(t *testing.T) {
+	c, err := di.New(
+		di.ResolverOnly(),
+		di.Provide(func() *http.Server { return &http.Server{} }),
+	)
+	require.NoError(t, err)
+	has, err := c.Has(new(*di.Container))
+	require.NoError(t, err)
+	require.False(t, has)
+	require.NoError(t, c.Invoke(func(resolver di.Resolver) error {
+		_, ok := resolver.(*di.Container)
+		require.False(t, ok)
+		var server *http.Server
+		return resolver.Resolve(&server)
+	}))
+	require.Empty(t, c.Unused())
+	err = c.Apply(di.ResolverOnly())
+	require.Regexp(t, `^.*container_test.go:\d+: di.ResolverOnly\(\) can be used only in di.New\(\)$`, err.Error())
+	_, err = di.New(
+		di.If(true, di.ResolverOnly()),
+	)
+	require.Regexp(t, `^.*container_test.go:\d+: di.ResolverOnly\(\) can't be applied conditionally$`, err.Error())
+	_, err = di.New(
+		di.When(func() bool { return true }, di.ResolverOnly()),
+	)
+	require.Error(t, err)
+}
+
+func TestContainer_Resolver(t *testing.T) {
+	t.Run("container provides resolver", func(t *testing.T) {
+		c, err := di.New(
+			di.Provide(func() *http.Server { return &http.Server{} }),
+			di.Provide(func() *http.ServeMux { return &http.ServeMux{} }, di.As(new(http.Handler))),
+		)
+		require.NoError(t, err)
+		var resolver di.Resolver
+		require.NoError(t, c.Resolve(&resolver))
+		_, ok := resolver.(*di.Container)
+		require.False(t, ok)
+		err = resolver.Resolve(new(*http.Client))
+		require.Regexp(t, `^.*container_test.go:\d+: type \*http.Client not exists in the container$`, err.Error())
+		has, err := resolver.Has(new(*http.Server))
+		require.NoError(t, err)
+		require.True(t, has)
+		var handlers []http.Handler
+		require.NoError(t, resolver.Iterate(&handlers, func(tags di.Tags, value di.ValueFunc) error {
+			_, err := value()
+			return err
+		}))
+	})
+
+	t.Run("resolver of child container resolves against child", func(t *testing.T) {
+		parent, err := di.New(
+			di.Provide(func() *http.ServeMux { return &http.ServeMux{} }),
+		)
+		require.NoError(t, err)
+		child, err := di.New(
+			di.Provide(func(mux *http.ServeMux) *http.Server { return &http.Server{Handler: mux} }),
+		)
+		require.NoError(t, err)
+		require.NoError(t, child.AddParent(parent))
+		require.NoError(t, child.Invoke(func(resolver di.Resolver, container *di.Container) error {
+			require.Equal(t, child, container)
+			var server *http.Server
+			return resolver.Resolve(&server)
+		}))
+		var resolver di.Resolver
+		require.NoError(t, parent.Resolve(&resolver))
+		has, err := resolver.Has(new(*http.Server))
+		require.NoError(t, err)
+		require.False(t, has)
+	})
+
+	t.Run("child container with resolver only hides container of parent", func(t *testing.T) {
 		parent, err := di.New()
 		require.NoError(t, err)
-		require.NotNil(t, parent)
-		err = parent.AddParent(parent)
-		require.Contains(t, err.Error(), "self cycle detected")
-		child, err := di.New()
+		child, err := di.New(di.ResolverOnly())
 		require.NoError(t, err)
-		require.NotNil(t, child)
-		err = child.AddParent(parent)
+		require.NoError(t, child.AddParent(parent))
+		has, err := child.Has(new(*di.Container))
 		require.NoError(t, err)
-		err = parent.AddParent(child)
-		require.Contains(t, err.Error(), "cycle detected")
-		err = child.AddParent(parent)
-		require.Contains(t, err.Error(), "parent already chained")
-		papaw, err := di.New()
+		require.False(t, has)
+		err = child.Resolve(new(*di.Container))
+		require.True(t, errors.Is(err, di.ErrTypeNotExists))
+	})
+
+	t.Run("type of parent gets resolver of parent", func(t *testing.T) {
+		type Service struct {
+			Resolver di.Resolver
+		}
+		var injected *di.Container
+		parent, err := di.New(
+			di.Provide(func(resolver di.Resolver, container *di.Container) *Service {
+				injected = container
+				return &Service{Resolver: resolver}
+			}),
+		)
 		require.NoError(t, err)
-		require.NotNil(t, papaw)
-		err = parent.AddParent(papaw)
-		err = papaw.AddParent(child)
-		require.Contains(t, err.Error(), "cycle detected")
+		child, err := di.New(
+			di.Provide(func() *http.Server { return &http.Server{} }),
+		)
+		require.NoError(t, err)
+		require.NoError(t, child.AddParent(parent))
+		var service *Service
+		require.NoError(t, child.Resolve(&service))
+		require.Equal(t, parent, injected)
+		has, err := service.Resolver.Has(new(*http.Server))
+		require.NoError(t, err)
+		require.False(t, has)
+		require.NoError(t, parent.Resolve(&service))
+		has, err = service.Resolver.Has(new(*http.Server))
+		require.NoError(t, err)
+		require.False(t, has)
 	})
 
+	t.Run("primary resolver replaces container", func(t *testing.T) {
+		var called bool
+		c, err := di.New(
+			di.Strict(),
+			di.Provide(func(c *di.Container) di.Resolver {
+				called = true
+				return c
+			}, di.Primary()),
+		)
+		require.NoError(t, err)
+		require.NoError(t, c.Resolve(new(di.Resolver)))
+		require.True(t, called)
+	})
 }
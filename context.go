@@ -0,0 +1,42 @@
+package di
+
+import (
+	"context"
+	"reflect"
+)
+
+var contextInterface = reflect.TypeOf(new(context.Context)).Elem()
+
+// withContext returns schema with context of resolution. Constructors with context.Context parameter
+// receive it, and resolution is aborted when it is done.
+func withContext(s schema, ctx context.Context) schema {
+	sc := toScope(s)
+	sc.ctx = ctx
+	return sc.schema()
+}
+
+// newContextNode creates node of context.Context value.
+func newContextNode(ctx context.Context) *node {
+	rv := reflect.ValueOf(&ctx).Elem()
+	return &node{
+		compiler: valueCompiler{rv: rv},
+		rt:       contextInterface,
+		rv:       new(reflect.Value),
+	}
+}
+
+// contextErr returns error of context of resolution if it is done.
+func contextErr(s schema) error {
+	if s, ok := s.(scope); ok && s.ctx != nil {
+		return s.ctx.Err()
+	}
+	return nil
+}
+
+// resolutionContext returns context of resolution or background context.
+func resolutionContext(s schema) context.Context {
+	if s, ok := s.(scope); ok && s.ctx != nil {
+		return s.ctx
+	}
+	return context.Background()
+}
@@ -17,25 +17,42 @@ func visit(s schema, node *node, marks map[*node]int) error {
 		return errCycleDetected // todo: improve message
 	}
 	marks[node] = temporary
+	// dependencies are resolved from the module of node
+	s = inNode(s, node)
 	params, err := node.deps(s)
 	if err != nil {
-		return fmt.Errorf("%s: %s", node, err)
+		return fmt.Errorf("%s: %w", node, withPath(node, err))
 	}
-	for _, param := range params {
+	for i, param := range params {
 		if err := visit(s, param, marks); err != nil {
-			return err
+			return withPath(node, node.requiredBy(err, i))
 		}
 	}
-	for _, field := range node.fields() {
+	for index, field := range node.fields() {
 		n, err := s.find(field.rt, field.tags)
 		if err != nil && field.optional {
 			continue
 		}
 		if err != nil {
-			return fmt.Errorf("%s: %s", node, err)
+			return fmt.Errorf("%s: %w", node, withPath(node, withField(err, node.fieldName(index))))
 		}
 		if err := visit(s, n, marks); err != nil {
-			return err
+			return withPath(node, withField(err, node.fieldName(index)))
+		}
+	}
+	wrappers, err := node.wrappers(s)
+	if err != nil {
+		return fmt.Errorf("%s: %w", node, withPath(node, err))
+	}
+	for _, w := range wrappers {
+		deps, err := w.deps(s)
+		if err != nil {
+			return fmt.Errorf("%s: %s decorator: %w", node, w, withPath(node, err))
+		}
+		for _, dep := range deps {
+			if err := visit(s, dep, marks); err != nil {
+				return withPath(node, err)
+			}
 		}
 	}
 	marks[node] = permanent
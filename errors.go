@@ -3,6 +3,9 @@ package di
 import (
 	"errors"
 	"fmt"
+	"io"
+	"reflect"
+	"strings"
 )
 
 var (
@@ -14,21 +17,357 @@ var (
 	errInvalidInvocationSignature = errors.New("invalid invocation signature")
 	errCycleDetected              = errors.New("cycle detected")
 	errFieldsNotSupported         = errors.New("fields not supported")
+	errPrivateType                = errors.New("private")
+	errMultipleDefinitions        = errors.New("multiple definitions")
 )
 
+// MissingError is an error of type that is not provided to the container.
+type MissingError struct {
+	// Type and Tags are the requested type and its tags.
+	Type reflect.Type
+	Tags Tags
+	// Path is a dependency path from the resolved type to the type that requires missing type.
+	Path []string
+	// Hops are the steps of Path with the parameters and fields that require the next step.
+	Hops []Hop
+	// Frame is a location where the type that requires missing type is provided.
+	Frame string
+	// Module is a name of module where the type is provided as private, empty if the type is not provided.
+	Module string
+	// Suggestions are definitions that are probably meant: the same type with other tags, the pointer or
+	// non-pointer variant and implementations of the interface.
+	Suggestions []string
+	// suggest finds suggestions, they are found only for errors that are reported
+	suggest func() []string
+	requirement
+}
+
+// Error is a string representation of error.
+func (e *MissingError) Error() string {
+	if e.Module != "" {
+		return fmt.Sprintf("type %s%s is %s in module %s", e.Type, e.Tags, errPrivateType, e.Module)
+	}
+	if suggestions := e.suggestions(); len(suggestions) > 0 {
+		return fmt.Sprintf("type %s%s %s, did you mean %s?", e.Type, e.Tags, ErrTypeNotExists, strings.Join(suggestions, " or "))
+	}
+	return fmt.Sprintf("type %s%s %s", e.Type, e.Tags, ErrTypeNotExists)
+}
+
+// suggestions returns Suggestions, they are found on the first call.
+func (e *MissingError) suggestions() []string {
+	if e.suggest != nil {
+		e.Suggestions, e.suggest = e.suggest(), nil
+	}
+	return e.Suggestions
+}
+
+// Unwrap returns ErrTypeNotExists, and the sentinel error of private type for private type.
+func (e *MissingError) Unwrap() []error {
+	if e.Module != "" {
+		return []error{ErrTypeNotExists, errPrivateType}
+	}
+	return []error{ErrTypeNotExists}
+}
+
+// Format formats error, the %+v verb adds the full dependency path.
+func (e *MissingError) Format(f fmt.State, verb rune) {
+	formatError(f, verb, e)
+}
+
+func (e *MissingError) writePath(w io.Writer) {
+	writePath(w, e.Hops, fmt.Sprintf("%s%s", e.Type, e.Tags))
+}
+
+func (e *MissingError) addPath(n *node) {
+	e.Path, e.Hops = e.prepend(e.Path, e.Hops, n)
+	if e.Frame == "" && n.frame.file != "" {
+		e.Frame = fmt.Sprint(n.frame)
+	}
+}
+
+// AmbiguousError is an error of type that has several definitions and none of them is the default.
+type AmbiguousError struct {
+	// Type and Tags are the requested type and its tags.
+	Type reflect.Type
+	Tags Tags
+	// Candidates are definitions of type with their tags and locations.
+	Candidates []string
+	// Path is a dependency path from the resolved type to the type that requires ambiguous type.
+	Path []string
+	// Hops are the steps of Path with the parameters and fields that require the next step.
+	Hops []Hop
+	// Frame is a location where the type that requires ambiguous type is provided.
+	Frame string
+	requirement
+}
+
+// Error is a string representation of error.
+func (e *AmbiguousError) Error() string {
+	return fmt.Sprintf("%s of %s%s, maybe you need to use group type: []%s%s; candidates: %s",
+		errMultipleDefinitions, e.Type, e.Tags, e.Type, e.Tags, strings.Join(e.Candidates, ", "))
+}
+
+// Unwrap returns the sentinel error of multiple definitions.
+func (e *AmbiguousError) Unwrap() error {
+	return errMultipleDefinitions
+}
+
+// Format formats error, the %+v verb adds the full dependency path.
+func (e *AmbiguousError) Format(f fmt.State, verb rune) {
+	formatError(f, verb, e)
+}
+
+func (e *AmbiguousError) writePath(w io.Writer) {
+	writePath(w, e.Hops, fmt.Sprintf("%s%s", e.Type, e.Tags))
+}
+
+func (e *AmbiguousError) addPath(n *node) {
+	e.Path, e.Hops = e.prepend(e.Path, e.Hops, n)
+	if e.Frame == "" && n.frame.file != "" {
+		e.Frame = fmt.Sprint(n.frame)
+	}
+}
+
+// SignatureError is an error of function that can't be used as constructor, invocation, decorator or
+// condition.
+type SignatureError struct {
+	// Kind is a kind of function: constructor, invocation, decorator or condition.
+	Kind string
+	// Type is a type of function, nil if function is nil.
+	Type reflect.Type
+	// Frame is a location where function is registered, empty if it is unknown.
+	Frame string
+}
+
+// Error is a string representation of error.
+func (e *SignatureError) Error() string {
+	if e.Type == nil {
+		return fmt.Sprintf("invalid %s signature, got nil", e.Kind)
+	}
+	return fmt.Sprintf("invalid %s signature, got %s", e.Kind, e.Type)
+}
+
+// Unwrap returns the sentinel error of invalid invocation.
+func (e *SignatureError) Unwrap() error {
+	if e.Kind == "invocation" {
+		return errInvalidInvocationSignature
+	}
+	return nil
+}
+
+// signatureAt sets location where function is registered to signature error.
+func signatureAt(frame callerFrame, err error) error {
+	var signature *SignatureError
+	if errors.As(err, &signature) && signature.Frame == "" {
+		signature.Frame = fmt.Sprint(frame)
+	}
+	return err
+}
+
+// ConstructorError is an error returned by constructor.
+type ConstructorError struct {
+	// Type and Tags are the type and tags of constructor result.
+	Type reflect.Type
+	Tags Tags
+	// Path is a dependency path from the resolved type to the constructed type.
+	Path []string
+	// Hops are the steps of Path with the parameters and fields that require the next step.
+	Hops []Hop
+	// Frame is a location where constructor is provided.
+	Frame string
+	// Err is an error returned by constructor.
+	Err error
+	requirement
+}
+
+// Error is a string representation of error.
+func (e *ConstructorError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns error returned by constructor.
+func (e *ConstructorError) Unwrap() error {
+	return e.Err
+}
+
+// Format formats error, the %+v verb adds the full dependency path.
+func (e *ConstructorError) Format(f fmt.State, verb rune) {
+	formatError(f, verb, e)
+}
+
+func (e *ConstructorError) writePath(w io.Writer) {
+	writePath(w, e.Hops, "")
+}
+
+func (e *ConstructorError) addPath(n *node) {
+	e.Path, e.Hops = e.prepend(e.Path, e.Hops, n)
+}
+
+// Hop is a step of dependency path.
+type Hop struct {
+	// Type is a type with tags.
+	Type string
+	// Param is an index of constructor parameter that requires the next step, -1 if it is not a parameter.
+	Param int
+	// Field is a name of injected field that requires the next step, empty if it is not a field.
+	Field string
+	// Frame is a location where the type is provided, empty if it is unknown.
+	Frame string
+}
+
+// String is a string representation of hop.
+func (h Hop) String() string {
+	if h.Frame == "" {
+		return h.Type
+	}
+	return fmt.Sprintf("%s at %s", h.Type, h.Frame)
+}
+
+// requires returns the description of parameter or field that requires the next step.
+func (h Hop) requires() string {
+	switch {
+	case h.Field != "":
+		return fmt.Sprintf("field %s: ", h.Field)
+	case h.Param >= 0:
+		return fmt.Sprintf("parameter %d: ", h.Param)
+	}
+	return ""
+}
+
+// pathError is an error that contains dependency path.
+type pathError interface {
+	error
+	addPath(n *node)
+	requiredBy(param int, field string)
+	writePath(w io.Writer)
+}
+
+// withPath adds node to the dependency path of error.
+func withPath(n *node, err error) error {
+	var pathErr pathError
+	if errors.As(err, &pathErr) {
+		pathErr.addPath(n)
+	}
+	return err
+}
+
+// withParam marks that the next node added to the dependency path requires the path by parameter.
+func withParam(err error, index int) error {
+	var pathErr pathError
+	if errors.As(err, &pathErr) {
+		pathErr.requiredBy(index, "")
+	}
+	return err
+}
+
+// withField marks that the next node added to the dependency path requires the path by field.
+func withField(err error, name string) error {
+	var pathErr pathError
+	if errors.As(err, &pathErr) {
+		pathErr.requiredBy(-1, name)
+	}
+	return err
+}
+
+// requirement is a parameter or field by which the next node requires the dependency path.
+type requirement struct {
+	param int // index of parameter plus one, zero if it is not a parameter
+	field string
+}
+
+func (r *requirement) requiredBy(param int, field string) {
+	r.param, r.field = param+1, field
+}
+
+// prepend adds node to the path if the path doesn't start with it.
+func (r *requirement) prepend(path []string, hops []Hop, n *node) ([]string, []Hop) {
+	hop := Hop{Type: n.String(), Param: r.param - 1, Field: r.field, Frame: n.frameString()}
+	*r = requirement{}
+	if len(path) > 0 && path[0] == n.String() {
+		if hops[0].Frame == "" {
+			hops[0].Frame = hop.Frame
+		}
+		if hop.requires() != "" {
+			hops[0].Param, hops[0].Field = hop.Param, hop.Field
+		}
+		return path, hops
+	}
+	return append([]string{n.String()}, path...), append([]Hop{hop}, hops...)
+}
+
+// formatError writes error message, the %+v verb adds the full dependency path.
+func formatError(f fmt.State, verb rune, err error) {
+	_, _ = io.WriteString(f, err.Error())
+	if verb != 'v' || !f.Flag('+') {
+		return
+	}
+	var pathErr pathError
+	if errors.As(err, &pathErr) {
+		pathErr.writePath(f)
+	}
+}
+
+// writePath writes dependency path line by line, last is the type which is required by the last hop.
+func writePath(w io.Writer, hops []Hop, last string) {
+	requires := ""
+	for _, hop := range hops {
+		_, _ = fmt.Fprintf(w, "\n\t%s%s", requires, hop)
+		requires = hop.requires()
+	}
+	if last != "" {
+		_, _ = fmt.Fprintf(w, "\n\t%s%s", requires, last)
+	}
+}
+
 // knownError return true if err is library known error.
 func knownError(err error) bool {
 	if errors.Is(err, ErrTypeNotExists) ||
 		errors.Is(err, errInvalidInvocationSignature) ||
 		errors.Is(err, errCycleDetected) ||
-		errors.Is(err, errFieldsNotSupported) {
+		errors.Is(err, errFieldsNotSupported) ||
+		errors.Is(err, errPrivateType) {
 		return true
 	}
 	return false
 }
 
 func errWithStack(err error) error {
-	return fmt.Errorf("%s: %w", stacktrace(1), err)
+	return &stackError{frame: stacktrace(1), err: reported(err)}
+}
+
+// errWithFrame adds location of option to error.
+func errWithFrame(frame callerFrame, err error) error {
+	return &stackError{frame: frame, err: reported(err)}
+}
+
+// reported finds suggestions of missing type error that is reported to the user.
+func reported(err error) error {
+	var missing *MissingError
+	if errors.As(err, &missing) {
+		missing.suggestions()
+	}
+	return err
+}
+
+// stackError is an error with location of container method call or option.
+type stackError struct {
+	frame callerFrame
+	err   error
+}
+
+// Error is a string representation of error.
+func (e *stackError) Error() string {
+	return fmt.Sprintf("%s: %s", e.frame, e.err)
+}
+
+// Unwrap returns the original error.
+func (e *stackError) Unwrap() error {
+	return e.err
+}
+
+// Format formats error, the %+v verb adds the full dependency path.
+func (e *stackError) Format(f fmt.State, verb rune) {
+	formatError(f, verb, e)
 }
 
 func bug() {
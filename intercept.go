@@ -0,0 +1,120 @@
+package di
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Interceptor is a function that is called instead of an interface method. The interceptor
+// receives method call and can inspect or modify arguments, call the next interceptor or
+// the original method with Call.Proceed() and replace results:
+//
+//	func Timing(call di.Call) []reflect.Value {
+//		start := time.Now()
+//		defer func() {
+//			log.Printf("%s.%s took %s", call.Interface, call.Method, time.Since(start))
+//		}()
+//		return call.Proceed()
+//	}
+type Interceptor func(call Call) []reflect.Value
+
+// Call is an intercepted method call.
+type Call struct {
+	// Interface is an intercepted interface type.
+	Interface reflect.Type
+	// Method is a name of called method.
+	Method string
+	// Args are method arguments. Variadic arguments are passed as a slice.
+	Args []reflect.Value
+	// target is an original value
+	target reflect.Value
+	// interceptors is a chain of interceptors, next is an index of the next one
+	interceptors []Interceptor
+	next         int
+}
+
+// Proceed calls the next interceptor in the chain or the original method and returns its results.
+func (c Call) Proceed() []reflect.Value {
+	if c.next < len(c.interceptors) {
+		interceptor := c.interceptors[c.next]
+		c.next++
+		return interceptor(c)
+	}
+	method := c.target.MethodByName(c.Method)
+	if method.Type().IsVariadic() {
+		return method.CallSlice(c.Args)
+	}
+	return method.Call(c.Args)
+}
+
+// ProxyHandler handles method calls of a proxy.
+type ProxyHandler func(method string, args []reflect.Value) []reflect.Value
+
+// ProxyFactory creates a proxy that implements an interface and routes every method call to the handler.
+// Proxy factories are generated by the di-proxy tool.
+type ProxyFactory func(handler ProxyHandler) interface{}
+
+var proxies = struct {
+	sync.RWMutex
+	factories map[reflect.Type]ProxyFactory
+}{factories: map[reflect.Type]ProxyFactory{}}
+
+// RegisterProxy registers proxy factory of interface. The argument is a pointer to interface. It is
+// called by the code generated by the di-proxy tool, so you don't need to call it manually:
+//
+//	//go:generate go run github.com/defval/di/cmd/di-proxy -type Repository
+func RegisterProxy(i Interface, factory ProxyFactory) {
+	link, err := inspectInterfacePointer(i)
+	if err != nil {
+		panic(err)
+	}
+	proxies.Lock()
+	defer proxies.Unlock()
+	proxies.factories[link.Type] = factory
+}
+
+// proxyFactory returns registered proxy factory of interface type.
+func proxyFactory(typ reflect.Type) (ProxyFactory, bool) {
+	proxies.RLock()
+	defer proxies.RUnlock()
+	factory, ok := proxies.factories[typ]
+	return factory, ok
+}
+
+// newInterceptWrapper creates decorator that replaces value of interface with a proxy.
+func newInterceptWrapper(typ reflect.Type, interceptors []Interceptor) (*wrapper, error) {
+	factory, ok := proxyFactory(typ)
+	if !ok {
+		return nil, fmt.Errorf("no proxy registered for %s, generate it with di-proxy", typ)
+	}
+	interceptors = append([]Interceptor(nil), interceptors...)
+	ft := reflect.FuncOf([]reflect.Type{typ}, []reflect.Type{typ}, false)
+	fn := reflect.MakeFunc(ft, func(args []reflect.Value) []reflect.Value {
+		target := args[0]
+		proxy := factory(func(method string, args []reflect.Value) []reflect.Value {
+			call := Call{
+				Interface:    typ,
+				Method:       method,
+				Args:         args,
+				target:       target,
+				interceptors: interceptors,
+			}
+			return call.Proceed()
+		})
+		result := reflect.New(typ).Elem()
+		result.Set(reflect.ValueOf(proxy))
+		return []reflect.Value{result}
+	})
+	return &wrapper{
+		fn: function{
+			Name:  fmt.Sprintf("%s interceptor", typ),
+			Type:  ft,
+			Value: fn,
+		},
+		typ: wrapperValue,
+		selector: func(rt reflect.Type, tags Tags) bool {
+			return rt == typ
+		},
+	}, nil
+}
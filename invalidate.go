@@ -0,0 +1,172 @@
+package di
+
+import (
+	"reflect"
+)
+
+// destructor is a cleanup function of node value. Value identifies nodes that share it.
+type destructor struct {
+	rv *reflect.Value
+	fn func()
+}
+
+// cleanupIndex returns count of cleanups registered in schema or in the stage of Reload().
+func cleanupIndex(s schema) int {
+	if st := stageOf(s); st != nil {
+		return len(st.cleanups)
+	}
+	base, _ := unscope(s)
+	g := base.generation.current()
+	g.RLock()
+	defer g.RUnlock()
+	return len(base.cleanups)
+}
+
+// own marks cleanups registered since index as cleanups of value.
+func own(s schema, index int, rv *reflect.Value) {
+	if st := stageOf(s); st != nil {
+		for i := index; i < len(st.cleanups); i++ {
+			st.cleanups[i].rv = rv
+		}
+		return
+	}
+	base, _ := unscope(s)
+	g := base.generation.current()
+	g.Lock()
+	defer g.Unlock()
+	for i := index; i < len(base.cleanups); i++ {
+		base.cleanups[i].rv = rv
+	}
+}
+
+// invalidate clears values of node and nodes that transitively depend on it, and runs their cleanups
+// in reverse order. The next resolve builds them again.
+func (s *defaultSchema) invalidate(n *node) {
+	affected := s.affected(n)
+	g := s.generation.current()
+	g.Lock()
+	for _, cur := range s.all() {
+		if !affected[cur.rv] {
+			continue
+		}
+		if cur.rv.IsValid() {
+			tracer.Trace("Invalidate %s", cur)
+		}
+		*cur.rv = reflect.Value{}
+		cur.value = reflect.Value{}
+	}
+	cleanups := s.takeCleanups(affected)
+	g.Unlock()
+	runCleanups(cleanups)
+}
+
+// affected returns values of node and nodes that transitively depend on it.
+func (s *defaultSchema) affected(n *node) map[*reflect.Value]bool {
+	nodes := s.all()
+	affected := map[*reflect.Value]bool{n.rv: true}
+	for changed := true; changed; {
+		changed = false
+		for _, cur := range nodes {
+			if affected[cur.rv] || !cur.built(s) {
+				continue
+			}
+			for _, dep := range s.dependencies(cur) {
+				if affected[dep.rv] {
+					affected[cur.rv] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return affected
+}
+
+// takeCleanups removes cleanups of affected values from schema and its ancestors and returns them in
+// registration order.
+func (s *defaultSchema) takeCleanups(affected map[*reflect.Value]bool) (taken []destructor) {
+	for _, schema := range s.ancestry() {
+		var cleanups []destructor
+		cleanups, taken = splitCleanups(schema.cleanups, affected, taken)
+		schema.cleanups = cleanups
+	}
+	return taken
+}
+
+// splitCleanups splits cleanups into cleanups of not affected values and cleanups of affected values
+// that are appended to taken.
+func splitCleanups(cleanups []destructor, affected map[*reflect.Value]bool, taken []destructor) (rest []destructor, _ []destructor) {
+	for _, cleanup := range cleanups {
+		if affected[cleanup.rv] {
+			taken = append(taken, cleanup)
+			continue
+		}
+		rest = append(rest, cleanup)
+	}
+	return rest, taken
+}
+
+// runCleanups runs cleanups in reverse order.
+func runCleanups(cleanups []destructor) {
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		if cleanups[i].fn != nil {
+			cleanups[i].fn()
+		}
+	}
+}
+
+// dependencies returns nodes that are used to build value of node: parameters, injected fields and
+// dependencies of decorators. Groups are replaced with their members. Live handles have no dependencies.
+func (s *defaultSchema) dependencies(n *node) (deps []*node) {
+	// Live handle is not rebuilt, it gets new value
+	if _, ok := n.compiler.(*liveCompiler); ok {
+		return nil
+	}
+	scope := inNode(s, n)
+	params, _ := n.deps(scope)
+	deps = append(deps, params...)
+	for _, field := range n.fields() {
+		if dep, err := scope.find(field.rt, field.tags); err == nil {
+			deps = append(deps, dep)
+		}
+	}
+	wrappers, _ := n.wrappers(scope)
+	for _, w := range wrappers {
+		params, _ := w.deps(scope)
+		deps = append(deps, params...)
+	}
+	result := make([]*node, 0, len(deps))
+	for _, dep := range deps {
+		if _, ok := dep.compiler.(*groupCompiler); ok {
+			result = append(result, s.dependencies(dep)...)
+			continue
+		}
+		result = append(result, dep)
+	}
+	return result
+}
+
+// all returns nodes of schema and its ancestors.
+func (s *defaultSchema) all() (nodes []*node) {
+	for _, schema := range s.ancestry() {
+		for _, list := range schema.nodes {
+			nodes = append(nodes, list...)
+		}
+	}
+	return nodes
+}
+
+// ancestry returns schema and its ancestors.
+func (s *defaultSchema) ancestry() []*defaultSchema {
+	result := []*defaultSchema{s}
+	visited := map[*defaultSchema]bool{s: true}
+	for i := 0; i < len(result); i++ {
+		for _, parent := range result[i].parents {
+			if !visited[parent] {
+				visited[parent] = true
+				result = append(result, parent)
+			}
+		}
+	}
+	return result
+}
@@ -13,9 +13,10 @@ func validateInvocation(fn function) bool {
 
 // parseInvocationParameters parses invocation and returns slice of nodes.
 func parseInvocationParameters(fn function, s schema) (params []*node, err error) {
+	names := paramNames(fn)
 	for i := 0; i < fn.NumIn(); i++ {
 		in := fn.Type.In(i)
-		node, err := s.find(in, Tags{})
+		node, err := findParam(s, in, paramName(names, i))
 		if err != nil {
 			return nil, err
 		}
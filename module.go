@@ -0,0 +1,155 @@
+package di
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// module is a named group of options. See di.Module().
+type module struct {
+	name  string
+	frame callerFrame
+}
+
+// String is a string representation of module.
+func (m *module) String() string {
+	return "module " + m.name
+}
+
+// struct that contains modules required by module.
+type importOptions struct {
+	frame   callerFrame
+	modules []string
+	module  *module
+}
+
+// scope is a schema viewed from the module with context of resolution. Private types of the module are
+// visible in its scope only.
+type scope struct {
+	*defaultSchema
+	module *module
+	ctx    context.Context
+	// owner is a container that owns the node which dependencies are resolved
+	owner *defaultSchema
+	// stage holds values that are rebuilt by Reload()
+	stage *stage
+	// evaluation holds conditions which dependencies are resolved, see di.When()
+	evaluation *evaluation
+}
+
+// find finds node visible from the module. The context.Context is the context of resolution. The container
+// itself is found in the owner of node.
+func (s scope) find(t reflect.Type, tags Tags) (*node, error) {
+	if s.owner != nil && isSelf(t) && len(tags) == 0 {
+		return s.owner.lookup(t, tags, nil, nil)
+	}
+	if s.ctx != nil && t == contextInterface && len(tags) == 0 {
+		return newContextNode(s.ctx), nil
+	}
+	return s.defaultSchema.lookup(t, tags, s.module, s.evaluation)
+}
+
+// cleanup registers cleanup in the stage of Reload() or in the schema.
+func (s scope) cleanup(cleanup func()) {
+	if s.stage != nil {
+		s.stage.cleanups = append(s.stage.cleanups, destructor{fn: cleanup})
+		return
+	}
+	s.defaultSchema.cleanup(cleanup)
+}
+
+// inModule returns schema viewed from the module. The context of resolution is kept.
+func inModule(s schema, m *module) schema {
+	sc := toScope(s)
+	sc.module, sc.owner = m, nil
+	return sc.schema()
+}
+
+// inNode returns schema viewed from the module of node. The container itself is resolved from the container
+// that owns node, not from the container where node is resolved. The context of resolution is kept.
+func inNode(s schema, n *node) schema {
+	sc := toScope(s)
+	sc.module, sc.owner = n.module, nil
+	if n.owner != sc.defaultSchema {
+		sc.owner = n.owner
+	}
+	return sc.schema()
+}
+
+// inWrapper returns schema viewed from the module of wrapper. The container itself is resolved from the
+// container where wrapper was registered. The context of resolution is kept.
+func inWrapper(s schema, w *wrapper) schema {
+	sc := toScope(s)
+	sc.module, sc.owner = w.module, nil
+	if w.schema != sc.defaultSchema {
+		sc.owner = w.schema
+	}
+	return sc.schema()
+}
+
+// unscope returns schema and context of resolution of scope.
+func unscope(s schema) (*defaultSchema, context.Context) {
+	sc := toScope(s)
+	return sc.defaultSchema, sc.ctx
+}
+
+// toScope returns scope of schema, the schema itself is a scope without module and context.
+func toScope(s schema) scope {
+	switch s := s.(type) {
+	case *defaultSchema:
+		return scope{defaultSchema: s}
+	case scope:
+		return s
+	}
+	bug()
+	return scope{}
+}
+
+// schema returns the schema itself if scope is empty.
+func (s scope) schema() schema {
+	if s.module == nil && s.ctx == nil && s.owner == nil && s.stage == nil && s.evaluation == nil {
+		return s.defaultSchema
+	}
+	return s
+}
+
+// visible excludes private nodes of other modules. Excluded nodes are returned separately to explain
+// why type is not found.
+func visible(nodes []*node, m *module) (result []*node, private []*node) {
+	result = make([]*node, 0, len(nodes))
+	for _, n := range nodes {
+		if n.private && n.module != m {
+			private = append(private, n)
+			continue
+		}
+		result = append(result, n)
+	}
+	return result, private
+}
+
+// addModule registers module in schema.
+func (s *defaultSchema) addModule(m *module) error {
+	if m.name == "" {
+		return fmt.Errorf("module name must not be empty")
+	}
+	if prev, ok := s.module(m.name); ok {
+		return fmt.Errorf("%s already registered at %s", m, prev.frame)
+	}
+	s.modules[m.name] = m
+	tracer.Trace("Register %s", m)
+	return nil
+}
+
+// module finds module by name in schema or its ancestors.
+func (s *defaultSchema) module(name string) (*module, bool) {
+	if m, ok := s.modules[name]; ok {
+		return m, true
+	}
+	for _, parent := range s.parents {
+		if m, ok := parent.module(name); ok {
+			return m, true
+		}
+	}
+	return nil, false
+}
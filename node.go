@@ -3,17 +3,18 @@ package di
 import (
 	"fmt"
 	"reflect"
+	"sync/atomic"
 )
 
 // newConstructorNode
 func newConstructorNode(ctor interface{}) (*node, error) {
 	f, valid := inspectFunction(ctor)
 	if !valid {
-		return nil, fmt.Errorf("invalid constructor signature, got %s", reflect.TypeOf(ctor))
+		return nil, &SignatureError{Kind: "constructor", Type: reflect.TypeOf(ctor)}
 	}
 	cmp, ok := newConstructorCompiler(f)
 	if !ok {
-		return nil, fmt.Errorf("invalid constructor signature, got %s", f.Type)
+		return nil, &SignatureError{Kind: "constructor", Type: f.Type}
 	}
 	// result type
 	rt := f.Out(0)
@@ -50,28 +51,136 @@ type node struct {
 	rv *reflect.Value
 	// decorators
 	decorators []Decorator
+	// primary marks node as the default definition of its type
+	primary bool
+	// frame is a location where node was provided
+	frame callerFrame
+	// origin is a node that was registered as interface
+	origin *node
+	// named groups of node
+	groups []string
+	// order and Before/After constraints of node in groups
+	order  int
+	before []Tags
+	after  []Tags
+	// flatten marks node which value is a slice of group members
+	flatten bool
+	// owner is a schema where node was registered
+	owner *defaultSchema
+	// value is a node value with applied wrappers
+	value reflect.Value
+	// module where node was provided, private node is visible in its module only
+	module  *module
+	private bool
+	// reloadable node is rebuilt by Reload(), live holds the current value for Live handles
+	reloadable bool
+	live       *atomic.Value
+	// used marks node which value was requested, see Unused(), inherited marks node which value was
+	// requested by child container
+	used      bool
+	inherited bool
+	// self marks node that provides container itself
+	self bool
+	// cond is a condition of conditional options where node was provided
+	cond *condition
 }
 
 // String is a string representation of node.
 func (n *node) String() string {
+	if n.module != nil {
+		return fmt.Sprintf("%s%s (%s)", n.rt, n.tags, n.module)
+	}
 	return fmt.Sprintf("%s%s", n.rt, n.tags)
 }
 
+// source describes provider of node with its location.
+func (n *node) source() string {
+	provided := n
+	if n.origin != nil {
+		provided = n.origin
+	}
+	if n.frame.file == "" {
+		return provided.String()
+	}
+	if n.cond.disabled() {
+		return fmt.Sprintf("%s at %s, disabled by condition at %s", provided, n.frame, n.cond.frame)
+	}
+	return fmt.Sprintf("%s at %s", provided, n.frame)
+}
+
+// frameString returns location where node was provided or empty string.
+func (n *node) frameString() string {
+	if n.frame.file == "" {
+		return ""
+	}
+	return fmt.Sprint(n.frame)
+}
+
 // Value returns value of node.
 func (n *node) Value(s schema) (reflect.Value, error) {
-	if n.rv.IsValid() {
-		return *n.rv, nil
+	n.use(s)
+	if v := n.loadValue(s); v.IsValid() {
+		return v, nil
+	}
+	wrappers, err := n.wrappers(s)
+	if err != nil {
+		return reflect.Value{}, withPath(n, err)
+	}
+	// conditions of decorators may resolve the node itself, it isn't decorated then
+	if v := n.loadValue(s); v.IsValid() {
+		return v, nil
+	}
+	rv, err := n.build(s)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	for _, w := range wrappers {
+		tracer.Trace("Run %s decorator for %s", w, n.String())
+		err = protect(s, n.String(), func() (err error) {
+			rv, err = w.wrap(s, rv)
+			return err
+		})
+		if err != nil {
+			tracer.Trace("Decorator error %s", err)
+			return reflect.Value{}, withPath(n, err)
+		}
 	}
+	n.storeValue(s, rv)
+	return rv, nil
+}
+
+// build builds node value that can be shared between nodes.
+func (n *node) build(s schema) (reflect.Value, error) {
+	if rv := n.loadRV(s); rv.IsValid() {
+		return rv, nil
+	}
+	// dependencies are resolved from the module of node
+	s = inNode(s, n)
 	nodes, _ := n.deps(s) // todo: error skipped, prepare already check dependency graph
 	var dependencies []reflect.Value
-	for _, node := range nodes {
+	for i, node := range nodes {
 		v, err := node.Value(s)
 		if err != nil {
-			return reflect.Value{}, fmt.Errorf("%s: %w", node, err)
+			return reflect.Value{}, fmt.Errorf("%s: %w", node, withPath(n, n.requiredBy(err, i)))
 		}
 		dependencies = append(dependencies, v)
 	}
-	rv, err := n.compile(dependencies, s)
+	// resolution is aborted between constructor calls
+	if err := contextErr(s); err != nil {
+		return reflect.Value{}, err
+	}
+	// cleanups registered by constructor belong to node value, see Invalidate()
+	index := cleanupIndex(s)
+	var rv reflect.Value
+	err := protect(s, n.String(), func() (err error) {
+		rv, err = n.compile(dependencies, s)
+		if _, ok := n.compiler.(*constructorCompiler); ok && err != nil {
+			err = &ConstructorError{Type: n.rt, Tags: n.tags, Path: []string{n.String()},
+				Hops: []Hop{{Type: n.String(), Param: -1, Frame: n.frameString()}}, Frame: n.frameString(), Err: err}
+		}
+		return err
+	})
+	own(s, index, n.rv)
 	if err != nil {
 		tracer.Trace("%s: %s", n.String(), err)
 		return reflect.Value{}, err
@@ -82,26 +191,188 @@ func (n *node) Value(s schema) (reflect.Value, error) {
 		addr.Elem().Set(rv)
 		rv = addr.Elem()
 	}
-	if err := populate(s, rv); err != nil {
-		tracer.Trace("%s: %s", n.String(), err)
-		return reflect.Value{}, err
+	for _, v := range n.elements(rv) {
+		if err := populate(s, v); err != nil {
+			tracer.Trace("%s: %s", n.String(), err)
+			return reflect.Value{}, withPath(n, err)
+		}
 	}
 	for _, decorator := range n.decorators {
 		tracer.Trace("Run resolve decorator for %s", n.String())
-		if err := decorator(rv.Interface()); err != nil {
+		err := protect(s, n.String(), func() error {
+			return decorator(rv.Interface())
+		})
+		if err != nil {
 			tracer.Trace("Decorator error %s", err)
 			return reflect.Value{}, err
 		}
 	}
-	*n.rv = rv
+	n.storeRV(s, rv)
 	tracer.Trace("Resolved %s", n.String())
-	return *n.rv, nil
+	return rv, nil
+}
+
+// use marks node and its origin as requested, node resolved in child container is marked as inherited.
+func (n *node) use(s schema) {
+	base, _ := unscope(s)
+	inherited := n.owner != nil && n.owner != base
+	g := base.generation.current()
+	g.RLock()
+	used := n.used && (n.origin == nil || n.origin.used) && (n.inherited || !inherited)
+	g.RUnlock()
+	if used {
+		return
+	}
+	g.Lock()
+	n.used = true
+	if n.origin != nil {
+		n.origin.used = true
+	}
+	if inherited {
+		n.inherited = true
+	}
+	g.Unlock()
+}
+
+// resolvedByChild returns true if value of node was requested by child container.
+func (n *node) resolvedByChild(s schema) bool {
+	g := generationOf(s)
+	g.RLock()
+	defer g.RUnlock()
+	return n.inherited
+}
+
+// built returns true if value of node is built.
+func (n *node) built(s schema) bool {
+	g := generationOf(s)
+	g.RLock()
+	defer g.RUnlock()
+	return n.rv.IsValid()
+}
+
+// loadRV returns shared value of node from the stage of Reload() or the current generation.
+func (n *node) loadRV(s schema) reflect.Value {
+	if st := stageOf(s); st.staged(n) {
+		return st.rvs[n.rv]
+	}
+	g := generationOf(s)
+	g.RLock()
+	defer g.RUnlock()
+	return *n.rv
+}
+
+// storeRV stores shared value of node into the stage of Reload() or the current generation.
+func (n *node) storeRV(s schema, rv reflect.Value) {
+	if st := stageOf(s); st.staged(n) {
+		st.rvs[n.rv] = rv
+		return
+	}
+	g := generationOf(s)
+	g.Lock()
+	defer g.Unlock()
+	*n.rv = rv
+}
+
+// loadValue returns value of node with applied wrappers from the stage of Reload() or the current generation.
+func (n *node) loadValue(s schema) reflect.Value {
+	if st := stageOf(s); st.staged(n) {
+		return st.values[n]
+	}
+	g := generationOf(s)
+	g.RLock()
+	defer g.RUnlock()
+	return n.value
+}
+
+// storeValue stores value of node with applied wrappers into the stage of Reload() or the current generation.
+// Live handles of staged value get it when Reload() publishes it.
+func (n *node) storeValue(s schema, value reflect.Value) {
+	if st := stageOf(s); st.staged(n) {
+		st.values[n] = value
+		return
+	}
+	g := generationOf(s)
+	g.Lock()
+	defer g.Unlock()
+	n.value = value
+	if n.live != nil {
+		n.live.Store(value)
+	}
+}
+
+// liveCell returns cell of Live handles of node, the cell is created with the current value. The cell of
+// staged value gets it when Reload() publishes it.
+func (n *node) liveCell(s schema, value reflect.Value) *atomic.Value {
+	g := generationOf(s)
+	g.Lock()
+	defer g.Unlock()
+	if n.live == nil {
+		n.live = new(atomic.Value)
+		if !stageOf(s).staged(n) {
+			n.live.Store(value)
+		}
+	}
+	return n.live
+}
+
+// wrappers returns value-replacing decorators of node. Decorators of conditional options decorate node if
+// their conditions are true, they don't decorate dependencies of their conditions.
+func (n *node) wrappers(s schema) ([]*wrapper, error) {
+	// flattened node value is a slice of members, it can't be replaced
+	if n.owner == nil || n.flatten {
+		return nil, nil
+	}
+	chain := toScope(s).evaluation
+	var wrappers []*wrapper
+	for _, w := range n.owner.wrappersOf(n, map[*defaultSchema]bool{}) {
+		if chain.has(w.cond) {
+			continue
+		}
+		ok, err := w.cond.enabled(chain)
+		if err != nil {
+			return nil, fmt.Errorf("%s decorator: %w", w, err)
+		}
+		if ok {
+			wrappers = append(wrappers, w)
+		}
+	}
+	return wrappers, nil
+}
+
+// elements returns group members of node value. The flattened node value is a slice
+// which elements are separate group members.
+func (n *node) elements(rv reflect.Value) []reflect.Value {
+	if !n.flatten {
+		return []reflect.Value{rv}
+	}
+	elements := make([]reflect.Value, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		elements = append(elements, rv.Index(i))
+	}
+	return elements
 }
 
 func (n *node) fields() map[int]field {
 	return parsePopulateFields(n.rt)
 }
 
+// requiredBy marks error of i-th dependency with the constructor parameter that requires it.
+func (n *node) requiredBy(err error, i int) error {
+	if _, ok := n.compiler.(*constructorCompiler); ok {
+		return withParam(err, i)
+	}
+	return err
+}
+
+// fieldName returns name of injected field by index.
+func (n *node) fieldName(index int) string {
+	rt := n.rt
+	if rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	return rt.Field(index).Name
+}
+
 // populate populates node fields.
 func populate(s schema, rv reflect.Value) error {
 	if !canInject(rv.Type()) {
@@ -118,15 +389,15 @@ func populate(s schema, rv reflect.Value) error {
 			continue
 		}
 		if err != nil {
-			return err
+			return withField(err, rv.Type().Field(index).Name)
 		}
 		v, err := node.Value(s)
 		if err != nil {
-			return err
+			return withField(err, rv.Type().Field(index).Name)
 		}
 		f := rv.Field(index)
 		if !f.CanSet() {
-			panic(fmt.Sprintf("can not set field %s(%d) of %s (addr: %t)", f.Type(), f.Pointer(), rv.Type(), rv.CanAddr()))
+			return fmt.Errorf("can not set field %s of %s", rv.Type().Field(index).Name, rv.Type())
 		}
 		f.Set(v)
 	}
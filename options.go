@@ -1,13 +1,32 @@
 package di
 
+import (
+	"os"
+	"time"
+)
+
 // Option is a functional option that configures container. If you don't know about functional
 // options, see https://dave.cheney.net/2014/10/17/functional-options-for-friendly-apis.
 // Below presented all possible options with their description:
 //
 //   - di.Provide - provide constructors
 //   - di.ProvideValue - provide value
+//   - di.ProvideConfig - provide configuration loaded from environment, files and flags
 //   - di.Invoke - add invocations
 //   - di.Resolve - resolves type
+//   - di.Wrap - add value-replacing decorators
+//   - di.WrapAll - add container-wide decorators
+//   - di.Intercept - add interceptors of interface methods
+//   - di.Module - groups options into a named module
+//   - di.Import - declares modules required by the module
+//   - di.If, di.IfEnv, di.When - apply options conditionally
+//   - di.WithDefaultPolicy - sets the policy of choosing between several definitions of the same type
+//   - di.DeclareGroup - declares named groups
+//   - di.AllowEmptyGroups - allows groups without members
+//   - di.RecoverPanics - converts panics into errors
+//   - di.Strict - rejects duplicate definitions
+//   - di.Sealed - seals container after options are applied
+//   - di.ResolverOnly - provides container as read-only di.Resolver
 type Option interface {
 	apply(c *diopts)
 }
@@ -19,9 +38,9 @@ func Provide(constructor Constructor, options ...ProvideOption) Option {
 	frame := stacktrace(0)
 	return option(func(c *diopts) {
 		c.provides = append(c.provides, provideOptions{
-			frame,
-			constructor,
-			options,
+			frame:       frame,
+			constructor: constructor,
+			options:     options,
 		})
 	})
 }
@@ -31,9 +50,44 @@ func ProvideValue(value Value, options ...ProvideOption) Option {
 	frame := stacktrace(0)
 	return option(func(c *diopts) {
 		c.values = append(c.values, provideValueOptions{
-			frame,
-			value,
-			options,
+			frame:   frame,
+			value:   value,
+			options: options,
+		})
+	})
+}
+
+// ProvideConfig returns container option that fills configuration structure and provides it as value.
+// Field values are taken from default tags first, then from sources in the order of arguments:
+//
+//	type Config struct {
+//		Addr     string        `env:"ADDR" flag:"addr" json:"addr" default:":8080"`
+//		Timeout  time.Duration `env:"TIMEOUT" default:"5s"`
+//		Database Database      `env:"DB_" json:"database" provide:"true"`
+//	}
+//
+//	type Database struct {
+//		DSN string `env:"DSN" json:"dsn" required:"true"` // APP_DB_DSN
+//	}
+//
+//	di.ProvideConfig(&Config{},
+//		di.FromFile("config.json"),
+//		di.FromEnv("APP_"),
+//		di.FromFlags(flag.CommandLine),
+//	)
+//
+// Fields with required:"true" tag must be set by the configuration itself, default tag or source,
+// explicitly set zero values count. Nested structures with provide:"true" tag are provided as separate
+// pointer types that point into configuration. The env tag of nested structure is a prefix of its
+// variables. Supported field types are strings, booleans, numbers, time.Duration, slices of them
+// separated by comma and types that implement encoding.TextUnmarshaler.
+func ProvideConfig(target Pointer, sources ...ConfigSource) Option {
+	frame := stacktrace(0)
+	return option(func(c *diopts) {
+		c.configs = append(c.configs, configOptions{
+			frame:   frame,
+			target:  target,
+			sources: sources,
 		})
 	})
 }
@@ -133,6 +187,91 @@ func WithName(name string) ProvideOption {
 	})
 }
 
+// Primary marks provided type as the default definition. If several definitions of the same type exist,
+// the untagged resolve picks the primary one, while tagged resolves still work as usual:
+//
+//	di.Provide(NewLeaderDB, di.Primary(), di.Tags{"type": "leader"}),
+//	di.Provide(NewFollowerDB, di.Tags{"type": "follower"}),
+//
+//	var db *Database
+//	container.Resolve(&db) // leader
+func Primary() ProvideOption {
+	return provideOption(func(params *ProvideParams) {
+		params.Primary = true
+	})
+}
+
+// Order returns provide option that sets position of provided type in groups. Group members with lower
+// order go first. Members with the same order keep registration order.
+//
+//	di.Provide(NewRecoveryMiddleware, di.As(new(Middleware)), di.Order(-10)),
+//	di.Provide(NewAuthMiddleware, di.As(new(Middleware)), di.Order(10)),
+func Order(n int) ProvideOption {
+	return provideOption(func(params *ProvideParams) {
+		params.Order = n
+	})
+}
+
+// Before returns provide option that places provided type in groups before members with matching tags.
+//
+//	di.Provide(NewCreateUsers, di.As(new(Migration)), di.Tags{"migration": "users"}),
+//	di.Provide(NewCreateOrders, di.As(new(Migration)), di.After(di.Tags{"migration": "users"})),
+func Before(tags Tags) ProvideOption {
+	return provideOption(func(params *ProvideParams) {
+		params.Before = append(params.Before, tags)
+	})
+}
+
+// After returns provide option that places provided type in groups after members with matching tags.
+// See Before().
+func After(tags Tags) ProvideOption {
+	return provideOption(func(params *ProvideParams) {
+		params.After = append(params.After, tags)
+	})
+}
+
+// Group returns provide option that adds provided type into named group. The named group is resolved
+// as slice or map of any type that group members are assignable to, with di.InGroup() or the di.group tag:
+//
+//	di.Provide(NewAuthPlugin, di.Group("plugins")),
+//	di.Provide(NewMetricsPlugin, di.Group("plugins")),
+//
+//	type Params struct {
+//		di.Inject
+//
+//		Plugins []Plugin `di:"di.group=plugins"`
+//	}
+//
+// Membership in named group doesn't require interface registration with di.As().
+func Group(names ...string) ProvideOption {
+	return provideOption(func(params *ProvideParams) {
+		params.Groups = append(params.Groups, names...)
+	})
+}
+
+// InGroup returns resolve option that selects members of named group, see di.Group():
+//
+//	var plugins []Plugin
+//	container.Resolve(&plugins, di.InGroup("plugins"))
+func InGroup(name string) ResolveOption {
+	return Tags{groupTag: name}
+}
+
+// Flatten returns provide option that contributes each element of provided slice as a separate
+// group member. The slice type itself is not registered, so it doesn't shadow the group:
+//
+//	di.Provide(NewAdminHandlers, di.Flatten()), // func() []Handler
+//	di.Provide(NewUserHandler),                 // func() Handler
+//
+//	var handlers []Handler // admin handlers and user handler
+//
+// Flattened elements can be resolved as group members only.
+func Flatten() ProvideOption {
+	return provideOption(func(params *ProvideParams) {
+		params.Flatten = true
+	})
+}
+
 // Decorator can modify container instance.
 type Decorator func(value Value) error
 
@@ -143,15 +282,62 @@ func Decorate(decorators ...Decorator) ProvideOption {
 	})
 }
 
+// Wrap returns container option that registers value-replacing decorator. See Container.Decorate() for details.
+//
+//	di.Provide(NewRepository, di.As(new(Repository))),
+//	di.Wrap(NewCachedRepository), // func(orig Repository, cache *Cache) (Repository, error)
+func Wrap(wrapper Wrapper, options ...ResolveOption) Option {
+	frame := stacktrace(0)
+	return option(func(c *diopts) {
+		c.wraps = append(c.wraps, wrapOptions{
+			frame:   frame,
+			wrapper: wrapper,
+			options: options,
+		})
+	})
+}
+
+// WrapAll returns container option that registers container-wide decorator. See Container.DecorateAll()
+// for details.
+//
+//	di.WrapAll(di.Implements(new(http.Handler)), NewLoggingHandler), // func(h http.Handler) http.Handler
+func WrapAll(matcher Matcher, wrapper Wrapper) Option {
+	frame := stacktrace(0)
+	return option(func(c *diopts) {
+		c.wraps = append(c.wraps, wrapOptions{
+			frame:   frame,
+			wrapper: wrapper,
+			matcher: matcher,
+		})
+	})
+}
+
+// Intercept returns container option that registers interceptors of interface methods. See Container.Intercept()
+// for details.
+//
+//	di.Provide(NewRepository, di.As(new(Repository))),
+//	di.Intercept(new(Repository), Tracing, Retry),
+func Intercept(i Interface, interceptors ...Interceptor) Option {
+	frame := stacktrace(0)
+	return option(func(c *diopts) {
+		c.wraps = append(c.wraps, wrapOptions{
+			frame:        frame,
+			intercept:    true,
+			iface:        i,
+			interceptors: interceptors,
+		})
+	})
+}
+
 // Resolve returns container options that resolves type into target. All resolves will be done on compile stage
 // after call invokes.
 func Resolve(target Pointer, options ...ResolveOption) Option {
 	frame := stacktrace(0)
 	return option(func(c *diopts) {
 		c.resolves = append(c.resolves, resolveOptions{
-			frame,
-			target,
-			options,
+			frame:   frame,
+			target:  target,
+			options: options,
 		})
 	})
 }
@@ -163,9 +349,80 @@ func Invoke(fn Invocation, options ...InvokeOption) Option {
 	frame := stacktrace(0)
 	return option(func(c *diopts) {
 		c.invokes = append(c.invokes, invokeOptions{
-			frame,
-			fn,
-			options,
+			frame:   frame,
+			fn:      fn,
+			options: options,
+		})
+	})
+}
+
+// DefaultPolicy describes how container chooses between several definitions of the same type
+// when the type is resolved without tags.
+type DefaultPolicy int
+
+const (
+	// PrimaryOnly picks the definition marked with di.Primary(). It is the default policy.
+	PrimaryOnly DefaultPolicy = iota
+	// PreferUntagged picks the definition marked with di.Primary() or, if there is no primary definition,
+	// the only definition without tags.
+	PreferUntagged
+)
+
+// WithDefaultPolicy returns container option that sets the policy of choosing between several
+// definitions of the same type. See DefaultPolicy for details.
+func WithDefaultPolicy(policy DefaultPolicy) Option {
+	return option(func(c *diopts) {
+		c.settings = append(c.settings, func(container *Container) {
+			container.schema.policy = policy
+		})
+	})
+}
+
+// DeclareGroup returns container option that declares named groups. The declared group without members
+// is resolved as empty slice or map. See Group().
+func DeclareGroup(names ...string) Option {
+	return option(func(c *diopts) {
+		c.settings = append(c.settings, func(container *Container) {
+			for _, name := range names {
+				container.schema.groups[name] = true
+			}
+		})
+	})
+}
+
+// AllowEmptyGroups returns container option that allows groups without members. Such groups
+// are resolved as empty slices or maps instead of error.
+func AllowEmptyGroups() Option {
+	return option(func(c *diopts) {
+		c.settings = append(c.settings, func(container *Container) {
+			container.schema.emptyGroups = true
+		})
+	})
+}
+
+// RecoverPanics returns container option that converts panics in constructors, decorators and invocations
+// into *di.PanicError with the panic value, the goroutine stack and the dependency path:
+//
+//	var panicErr *di.PanicError
+//	if errors.As(err, &panicErr) {
+//		log.Printf("%s: %v\n%s", strings.Join(panicErr.Path, " -> "), panicErr.Value, panicErr.Stack)
+//	}
+func RecoverPanics() Option {
+	return option(func(c *diopts) {
+		c.settings = append(c.settings, func(container *Container) {
+			container.schema.recoverPanics = true
+		})
+	})
+}
+
+// Strict returns container option that rejects untagged duplicate definitions of the same type when
+// they are provided instead of failing on resolve. Definitions with tags, di.Primary(), di.Group() and
+// di.Flatten() are not duplicates. Interfaces of di.As() are not checked, so several implementations
+// still form a group.
+func Strict() Option {
+	return option(func(c *diopts) {
+		c.settings = append(c.settings, func(container *Container) {
+			container.schema.strict = true
 		})
 	})
 }
@@ -195,12 +452,182 @@ func Options(options ...Option) Option {
 	})
 }
 
+// Module returns container option that groups options into a named module. Unlike di.Options(), module
+// has an identity: types provided with di.Private() are injectable only inside the module, di.Import()
+// declares modules it requires, and errors and the tracer report the module of provided type.
+//
+//	billing := di.Module("billing",
+//		di.Import("db"),
+//		di.Provide(NewInvoiceRepository, di.Private()),
+//		di.Provide(NewBillingService),
+//	)
+//	container, err := di.New(db, billing)
+//
+// Module names must be unique in the container. Modules can be nested.
+func Module(name string, options ...Option) Option {
+	frame := stacktrace(0)
+	return option(func(c *diopts) {
+		m := &module{
+			name:  name,
+			frame: frame,
+		}
+		inner := diopts{}
+		for _, opt := range options {
+			opt.apply(&inner)
+		}
+		inner.inModule(m)
+		c.modules = append(c.modules, m)
+		c.merge(inner)
+	})
+}
+
+// Import returns container option that declares modules required by the module. Missing modules are
+// reported before any type is provided:
+//
+//	di.Module("billing",
+//		di.Import("db", "logging"),
+//		di.Provide(NewBillingService),
+//	)
+//
+// Used outside di.Module(), it declares modules required by the container.
+func Import(modules ...string) Option {
+	frame := stacktrace(0)
+	return option(func(c *diopts) {
+		c.imports = append(c.imports, importOptions{
+			frame:   frame,
+			modules: modules,
+		})
+	})
+}
+
+// Private returns provide option that makes provided type injectable only inside its module. Private
+// types are not resolvable from the container and are not members of groups outside the module. It can
+// be used only inside di.Module().
+func Private() ProvideOption {
+	return provideOption(func(params *ProvideParams) {
+		params.Private = true
+	})
+}
+
+// Reloadable returns provide option that allows to rebuild provided type with container.Reload(). Types
+// that depend on it are rebuilt too, consumers that must see new values use di.Live handle:
+//
+//	di.Provide(LoadConfig, di.Reloadable())
+//	di.Provide(func(config di.Live[*Config]) *Handler { ... })
+func Reloadable() ProvideOption {
+	return provideOption(func(params *ProvideParams) {
+		params.Reloadable = true
+	})
+}
+
+// Timeout returns provide option that limits duration of constructor call. Constructor parameters of
+// context.Context type are cancelled when the timeout is exceeded, so slow dials are interrupted. The
+// context of successful call isn't cancelled. The resolution fails with *di.TimeoutError that matches
+// context.DeadlineExceeded when the timeout is exceeded, even if the constructor doesn't accept context.
+// Such constructor keeps running in background, and the cleanup of its late result is called. Its late
+// panic is traced with di.RecoverPanics() and raised again without it.
+func Timeout(d time.Duration) ProvideOption {
+	return provideOption(func(params *ProvideParams) {
+		params.Timeout = d
+	})
+}
+
+// Retry returns provide option that calls failing constructor again with backoff. The results of failed
+// calls are cleaned up. If all attempts fail, the resolution fails with *di.RetryError:
+//
+//	di.Provide(NewDB, di.Retry(di.RetryPolicy{Attempts: 5, Delay: 100 * time.Millisecond}))
+//
+// Use it with di.Timeout() to limit each attempt.
+func Retry(policy RetryPolicy) ProvideOption {
+	return provideOption(func(params *ProvideParams) {
+		params.Retry = &policy
+	})
+}
+
+// If returns container option that applies options if the condition is true. Unlike wiring inside
+// di.Invoke(), conditional options are declared in the container options and are registered by the
+// container itself:
+//
+//	di.If(debug, di.Provide(NewDebugHandler, di.As(new(http.Handler)))),
+//
+// Types and decorators of skipped options stay registered but disabled: they are not resolved, and
+// container.Unused() reports them with the location of the condition.
+func If(condition bool, options ...Option) Option {
+	frame := stacktrace(0)
+	return option(func(c *diopts) {
+		c.conditions = append(c.conditions, conditionOptions{
+			frame:   frame,
+			value:   condition,
+			options: options,
+		})
+	})
+}
+
+// IfEnv returns container option that applies options if the environment variable equals the value.
+// The variable is read when options are applied to the container:
+//
+//	di.IfEnv("CONNECTION_TYPE", "udp", di.Provide(NewUDPConn, di.As(new(net.Conn)))),
+func IfEnv(key, value string, options ...Option) Option {
+	frame := stacktrace(0)
+	return option(func(c *diopts) {
+		c.conditions = append(c.conditions, conditionOptions{
+			frame:   frame,
+			value:   os.Getenv(key) == value,
+			options: options,
+		})
+	})
+}
+
+// Condition is a function that decides whether conditional options are applied:
+//
+//	func IsUDP(conf *Configuration) bool {
+//		return conf.ConnectionType == "udp"
+//	}
+//
+// Like an invocation, the condition may have unlimited count of arguments and they will be
+// resolved automatically.
+type Condition interface{}
+
+// When returns container option that applies options if the condition function returns true. The
+// arguments of the function are resolved from the container like invocation arguments:
+//
+//	di.Provide(NewConfiguration),
+//	di.When(func(conf *Configuration) bool {
+//		return conf.ConnectionType == "udp"
+//	}, di.Provide(NewUDPConn, di.As(new(net.Conn)))),
+//
+// The condition is evaluated once, on the first lookup of its types or decorators, or before its
+// invocations and resolves, so the arguments are not built while options are applied. Conditional
+// options with container settings, such as di.Strict(), are evaluated when options are applied. Types
+// resolved by the condition are not decorated by conditional decorators.
+func When(condition Condition, options ...Option) Option {
+	frame := stacktrace(0)
+	return option(func(c *diopts) {
+		c.conditions = append(c.conditions, conditionOptions{
+			frame:   frame,
+			when:    true,
+			fn:      condition,
+			options: options,
+		})
+	})
+}
+
 // ProvideParams is a Provide() method options. Name is a unique identifier of type instance. Provider is a constructor
 // function. Interfaces is a interface that implements a provider result type.
 type ProvideParams struct {
 	Tags       Tags
 	Interfaces []Interface
 	Decorators []Decorator
+	Primary    bool
+	Order      int
+	Before     []Tags
+	After      []Tags
+	Flatten    bool
+	Groups     []string
+	Private    bool
+	Reloadable bool
+	Timeout    time.Duration
+	Retry      *RetryPolicy
 }
 
 func (p ProvideParams) applyProvide(params *ProvideParams) {
@@ -269,6 +696,7 @@ type provideOptions struct {
 	frame       callerFrame
 	constructor Constructor
 	options     []ProvideOption
+	module      *module
 }
 
 // struct that contains value with options.
@@ -276,6 +704,20 @@ type provideValueOptions struct {
 	frame   callerFrame
 	value   Value
 	options []ProvideOption
+	module  *module
+}
+
+// struct that contains wrapper with options.
+type wrapOptions struct {
+	frame   callerFrame
+	wrapper Wrapper
+	options []ResolveOption
+	matcher Matcher
+	// intercepted interface and its interceptors
+	intercept    bool
+	iface        Interface
+	interceptors []Interceptor
+	module       *module
 }
 
 // struct that contains invoke function with options.
@@ -283,6 +725,19 @@ type invokeOptions struct {
 	frame   callerFrame
 	fn      Invocation
 	options []InvokeOption
+	module  *module
+	cond    *condition
+}
+
+// struct that contains condition with conditional options.
+type conditionOptions struct {
+	frame callerFrame
+	// value of di.If() and di.IfEnv() condition, fn of di.When() condition
+	value   bool
+	when    bool
+	fn      Condition
+	options []Option
+	module  *module
 }
 
 // struct that container resolve target with options.
@@ -290,4 +745,6 @@ type resolveOptions struct {
 	frame   callerFrame
 	target  Pointer
 	options []ResolveOption
+	module  *module
+	cond    *condition
 }
@@ -0,0 +1,71 @@
+package di
+
+import (
+	"fmt"
+)
+
+// sortGroup sorts group members by their order and Before/After constraints. Members
+// without constraints keep registration order.
+func sortGroup(nodes []*node) ([]*node, error) {
+	// follows[i][j] is true if nodes[j] must follow nodes[i]
+	follows := make([][]bool, len(nodes))
+	for i := range follows {
+		follows[i] = make([]bool, len(nodes))
+	}
+	for i, a := range nodes {
+		for j, b := range nodes {
+			if i == j {
+				continue
+			}
+			for _, tags := range a.before {
+				if b.tags.match(tags) {
+					follows[i][j] = true
+				}
+			}
+			for _, tags := range a.after {
+				if b.tags.match(tags) {
+					follows[j][i] = true
+				}
+			}
+		}
+	}
+	preceding := make([]int, len(nodes))
+	for i := range follows {
+		for j := range follows[i] {
+			if follows[i][j] {
+				preceding[j]++
+			}
+		}
+	}
+	sorted := make([]*node, 0, len(nodes))
+	done := make([]bool, len(nodes))
+	for len(sorted) < len(nodes) {
+		// take ready node with the lowest order, registration order breaks ties
+		next := -1
+		for i, n := range nodes {
+			if done[i] || preceding[i] > 0 {
+				continue
+			}
+			if next == -1 || n.order < nodes[next].order {
+				next = i
+			}
+		}
+		if next == -1 {
+			var cycle []*node
+			for i, n := range nodes {
+				if !done[i] {
+					cycle = append(cycle, n)
+				}
+			}
+			return nil, fmt.Errorf("%w in group order: %s", errCycleDetected, candidates(cycle))
+		}
+		done[next] = true
+		sorted = append(sorted, nodes[next])
+		for j := range follows[next] {
+			if follows[next][j] {
+				preceding[j]--
+			}
+		}
+	}
+	return sorted, nil
+}
@@ -0,0 +1,64 @@
+package di
+
+import (
+	"fmt"
+	"io"
+	"runtime/debug"
+)
+
+// PanicError is an error of panic recovered in constructor, decorator or invocation. See di.RecoverPanics().
+type PanicError struct {
+	// Value is a value passed to panic().
+	Value interface{}
+	// Stack is a stack of goroutine at the moment of panic.
+	Stack []byte
+	// Path is a dependency path from resolved type to the type which constructor or decorator panicked.
+	Path []string
+	// Hops are the steps of Path with the parameters and fields that require the next step.
+	Hops []Hop
+	requirement
+}
+
+// Error is a string representation of error.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// protect calls fn and converts its panic into *PanicError if the container recovers panics.
+func protect(s schema, name string, fn func() error) (err error) {
+	base, _ := unscope(s)
+	if !base.recoverPanics {
+		return fn()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			stack := debug.Stack()
+			// panic of constructor with timeout is raised again with the stack of its goroutine
+			if p, ok := r.(*ctorPanic); ok {
+				r, stack = p.value, p.stack
+			}
+			tracer.Trace("Recovered panic in %s: %v", name, r)
+			err = &PanicError{Value: r, Stack: stack, Path: []string{name}, Hops: []Hop{{Type: name, Param: -1}}}
+		}
+	}()
+	return fn()
+}
+
+// Format formats error, the %+v verb adds the full dependency path.
+func (e *PanicError) Format(f fmt.State, verb rune) {
+	formatError(f, verb, e)
+}
+
+func (e *PanicError) writePath(w io.Writer) {
+	writePath(w, e.Hops, "")
+}
+
+func (e *PanicError) addPath(n *node) {
+	e.Path, e.Hops = e.prepend(e.Path, e.Hops, n)
+}
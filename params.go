@@ -0,0 +1,68 @@
+package di
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+var paramRegistry = struct {
+	sync.RWMutex
+	names map[uintptr][]string
+}{names: map[uintptr][]string{}}
+
+// ParamNames registers parameter names of function. Go reflection loses parameter names, so they are
+// recorded by the di-params code generator:
+//
+//	//go:generate go run github.com/defval/di/cmd/di-params
+//
+// If the type of parameter has several definitions, the container resolves it by the parameter name
+// as with di.Tags{"name": name}:
+//
+//	func NewServer(addr string, metricsAddr string) *Server
+//
+//	di.ProvideValue(":8080", di.WithName("addr")),
+//	di.ProvideValue(":9090", di.WithName("metricsAddr")),
+//	di.Provide(NewServer),
+//
+// Empty name means unnamed parameter.
+func ParamNames(fn interface{}, names ...string) {
+	f, valid := inspectFunction(fn)
+	if !valid {
+		panic(fmt.Sprintf("function expected, got %s", reflect.TypeOf(fn)))
+	}
+	if len(names) != f.NumIn() {
+		panic(fmt.Sprintf("%s: %d parameter names expected, got %d", f.Name, f.NumIn(), len(names)))
+	}
+	paramRegistry.Lock()
+	defer paramRegistry.Unlock()
+	paramRegistry.names[f.Pointer()] = names
+}
+
+// paramNames returns registered parameter names of function.
+func paramNames(fn function) []string {
+	paramRegistry.RLock()
+	defer paramRegistry.RUnlock()
+	return paramRegistry.names[fn.Pointer()]
+}
+
+// paramName returns name of parameter i or empty string if name is unknown.
+func paramName(names []string, i int) string {
+	if i < len(names) {
+		return names[i]
+	}
+	return ""
+}
+
+// findParam finds node of function parameter. If the type has several definitions, it is found by the
+// parameter name.
+func findParam(s schema, t reflect.Type, name string) (*node, error) {
+	node, err := s.find(t, Tags{})
+	if errors.Is(err, errMultipleDefinitions) && name != "" {
+		if named, nerr := s.find(t, Tags{"name": name}); nerr == nil {
+			return named, nil
+		}
+	}
+	return node, err
+}
@@ -0,0 +1,212 @@
+package di
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Live is a handle of value that is replaced by container.Reload(). Use it as constructor parameter or
+// injected field to always get the current generation of value:
+//
+//	func NewHandler(config di.Live[*Config]) *Handler {
+//		return &Handler{config: config}
+//	}
+//
+//	func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+//		config := h.config.Load()
+//		// ...
+//	}
+//
+// Load is safe to call concurrently with container.Reload().
+type Live[T any] struct {
+	cell *atomic.Value
+}
+
+// Load returns the current value.
+func (l Live[T]) Load() T {
+	var value T
+	if l.cell == nil {
+		return value
+	}
+	rv, _ := l.cell.Load().(reflect.Value)
+	if !rv.IsValid() {
+		return value
+	}
+	value, _ = rv.Interface().(T)
+	return value
+}
+
+func (l Live[T]) liveType() reflect.Type {
+	return reflect.TypeOf(new(T)).Elem()
+}
+
+func (l *Live[T]) setCell(cell *atomic.Value) {
+	l.cell = cell
+}
+
+// liveHandle is implemented by Live.
+type liveHandle interface {
+	liveType() reflect.Type
+}
+
+var liveHandleInterface = reflect.TypeOf(new(liveHandle)).Elem()
+
+// isLive checks that t is a Live handle.
+func isLive(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t.Implements(liveHandleInterface)
+}
+
+// liveCompiler compiles Live handle of node with rt type and tags.
+type liveCompiler struct {
+	rt   reflect.Type
+	tags Tags
+}
+
+// newLiveCompiler creates compiler of Live handle.
+func newLiveCompiler(rt reflect.Type, tags Tags) *liveCompiler {
+	return &liveCompiler{
+		rt:   rt,
+		tags: tags,
+	}
+}
+
+func (c *liveCompiler) deps(s schema) ([]*node, error) {
+	typ := reflect.Zero(c.rt).Interface().(liveHandle).liveType()
+	target, err := s.find(typ, c.tags)
+	if err != nil {
+		return nil, err
+	}
+	return []*node{target}, nil
+}
+
+func (c *liveCompiler) compile(dependencies []reflect.Value, s schema) (reflect.Value, error) {
+	nodes, err := c.deps(s)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	cell := nodes[0].liveCell(s, dependencies[0])
+	rv := reflect.New(c.rt)
+	rv.Interface().(interface{ setCell(*atomic.Value) }).setCell(cell)
+	return rv.Elem(), nil
+}
+
+// generation guards values of nodes and cleanups of containers chained together: resolves read values
+// under it, Reload() publishes new values under it at once. Containers share the generation of their
+// parents, see container.AddParent().
+type generation struct {
+	sync.RWMutex
+	// merged is a generation that replaced this one
+	merged *generation
+}
+
+// current returns the generation that is used instead of merged ones.
+func (g *generation) current() *generation {
+	for g.merged != nil {
+		g = g.merged
+	}
+	return g
+}
+
+// merge makes generation and other one the same generation.
+func (g *generation) merge(other *generation) {
+	g, other = g.current(), other.current()
+	if g != other {
+		g.merged = other
+	}
+}
+
+// generationOf returns generation of schema.
+func generationOf(s schema) *generation {
+	base, _ := unscope(s)
+	return base.generation.current()
+}
+
+// stage holds values of nodes that are rebuilt by Reload() and cleanups registered by their constructors
+// until they are published. Values of the current generation stay untouched, so concurrent resolves get
+// them meanwhile.
+type stage struct {
+	affected map[*reflect.Value]bool
+	rvs      map[*reflect.Value]reflect.Value
+	values   map[*node]reflect.Value
+	cleanups []destructor
+}
+
+// stageOf returns stage of Reload() the schema is built in, nil if it isn't.
+func stageOf(s schema) *stage {
+	if s, ok := s.(scope); ok {
+		return s.stage
+	}
+	return nil
+}
+
+// withStage returns schema that builds affected values of stage into it.
+func withStage(s schema, st *stage) schema {
+	sc := toScope(s)
+	sc.stage = st
+	return sc.schema()
+}
+
+// staged returns true if value of node is rebuilt in stage.
+func (st *stage) staged(n *node) bool {
+	return st != nil && st.affected[n.rv]
+}
+
+// reload builds new values of node and nodes that transitively depend on it off to the side. New values
+// replace old ones at once only if all of them are built, then cleanups of old values are run in reverse
+// order and Live handles get new values. If building fails, the cleanups of new values are run and old
+// values are kept. Values resolved in child containers are not reloaded, children would keep old ones.
+func (s *defaultSchema) reload(n *node) error {
+	if !n.reloadable {
+		return fmt.Errorf("%s is not reloadable, use di.Reloadable() provide option", n)
+	}
+	s.reloading.Lock()
+	defer s.reloading.Unlock()
+	st := &stage{
+		affected: s.affected(n),
+		rvs:      map[*reflect.Value]reflect.Value{},
+		values:   map[*node]reflect.Value{},
+	}
+	var nodes []*node
+	for _, cur := range s.all() {
+		if !st.affected[cur.rv] || !cur.built(s) {
+			continue
+		}
+		if cur.resolvedByChild(s) {
+			return fmt.Errorf("%s is resolved in child container, it can't be reloaded", cur)
+		}
+		nodes = append(nodes, cur)
+	}
+	g := generationOf(s)
+	staged := withStage(s, st)
+	for _, cur := range nodes {
+		if _, err := cur.Value(staged); err != nil {
+			// values of not affected nodes built meanwhile are kept with their cleanups
+			rest, fresh := splitCleanups(st.cleanups, st.affected, nil)
+			g.Lock()
+			s.cleanups = append(s.cleanups, rest...)
+			g.Unlock()
+			runCleanups(fresh)
+			return fmt.Errorf("%s: %w", cur, err)
+		}
+	}
+	g.Lock()
+	for rv, value := range st.rvs {
+		*rv = value
+	}
+	for cur, value := range st.values {
+		cur.value = value
+		if cur.live != nil {
+			cur.live.Store(value)
+		}
+	}
+	cleanups := s.takeCleanups(st.affected)
+	s.cleanups = append(s.cleanups, st.cleanups...)
+	g.Unlock()
+	for _, cur := range nodes {
+		tracer.Trace("Reloaded %s", cur)
+	}
+	runCleanups(cleanups)
+	return nil
+}
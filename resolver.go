@@ -0,0 +1,106 @@
+package di
+
+import (
+	"reflect"
+)
+
+var (
+	containerType = reflect.TypeOf(new(Container))
+	resolverType  = reflect.TypeOf(new(Resolver)).Elem()
+)
+
+// Resolver is a read-only view of container. Components can depend on it instead of *di.Container
+// to resolve types without access to registrations:
+//
+//	func NewRouter(resolver di.Resolver) *Router {
+//		return &Router{resolver: resolver}
+//	}
+//
+// The container provides itself as Resolver. In a child container Resolver resolves types from the
+// child container, not from its parents.
+type Resolver interface {
+	// Resolve resolves type and fills target pointer, see container.Resolve().
+	Resolve(ptr Pointer, options ...ResolveOption) error
+	// Has checks that type exists in container, see container.Has().
+	Has(target Pointer, options ...ResolveOption) (bool, error)
+	// Iterate iterates over group of type, see container.Iterate().
+	Iterate(target Pointer, fn IterateFunc, options ...ResolveOption) error
+}
+
+var _ Resolver = (*Container)(nil)
+
+// resolver is a Resolver of container that hides other methods of container.
+type resolver struct {
+	c *Container
+}
+
+// Resolve resolves type and fills target pointer.
+func (r resolver) Resolve(ptr Pointer, options ...ResolveOption) error {
+	if err := r.c.resolve(r.c.schema, ptr, options...); err != nil {
+		return errWithStack(err)
+	}
+	return nil
+}
+
+// Has checks that type exists in container.
+func (r resolver) Has(target Pointer, options ...ResolveOption) (bool, error) {
+	return r.c.Has(target, options...)
+}
+
+// Iterate iterates over group of type.
+func (r resolver) Iterate(target Pointer, fn IterateFunc, options ...ResolveOption) error {
+	return r.c.Iterate(target, fn, options...)
+}
+
+// ResolverOnly returns container option that provides container only as di.Resolver without *di.Container,
+// so components can't register types at runtime. The option can be used only in di.New(), container.Apply()
+// and conditional options reject it.
+func ResolverOnly() Option {
+	return option(func(c *diopts) {
+		c.resolverOnly = true
+	})
+}
+
+// provideSelf provides container as *di.Container and di.Resolver, or only as di.Resolver.
+func (c *Container) provideSelf(frame callerFrame, resolverOnly bool) {
+	var constructors []interface{}
+	if !resolverOnly {
+		constructors = append(constructors, func() *Container { return c })
+	}
+	constructors = append(constructors, func() Resolver { return resolver{c: c} })
+	for _, ctor := range constructors {
+		n, _ := newConstructorNode(ctor)
+		n.frame = frame
+		n.self = true
+		c.schema.register(n)
+	}
+}
+
+// isSelf returns true if type is provided by container itself.
+func isSelf(t reflect.Type) bool {
+	return t == containerType || t == resolverType
+}
+
+// scoped excludes nodes that provide ancestors of schema, the container resolves only itself. With
+// di.ResolverOnly() the container doesn't resolve *di.Container of its parents.
+func (s *defaultSchema) scoped(nodes []*node) []*node {
+	result := make([]*node, 0, len(nodes))
+	for _, n := range nodes {
+		if n.self && n.owner != s {
+			continue
+		}
+		result = append(result, n)
+	}
+	return result
+}
+
+// closest returns the node that provides the schema itself, own definitions of container shadow
+// definitions of its parents.
+func (s *defaultSchema) closest(nodes []*node) (*node, bool) {
+	for _, n := range nodes {
+		if n.self && n.owner == s {
+			return n, true
+		}
+	}
+	return nil, false
+}
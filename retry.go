@@ -0,0 +1,79 @@
+package di
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// RetryPolicy describes how a failing constructor is called again. See di.Retry().
+type RetryPolicy struct {
+	// Attempts is a maximum number of constructor calls including the first one.
+	Attempts int
+	// Delay is a delay before the second call. It is doubled before each next call.
+	Delay time.Duration
+	// MaxDelay limits the delay between calls. Zero means no limit.
+	MaxDelay time.Duration
+}
+
+// delay returns delay after the failed attempt.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	delay := p.Delay
+	for i := 1; i < attempt && delay < math.MaxInt64/2; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			break
+		}
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+// RetryError is an error of constructor that failed all attempts of di.Retry() policy.
+type RetryError struct {
+	// Attempts is a number of constructor calls.
+	Attempts int
+	// Err is an error of the last call.
+	Err error
+}
+
+// Error is a string representation of error.
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("failed after %d attempts: %s", e.Attempts, e.Err)
+}
+
+// Unwrap returns error of the last call.
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// TimeoutError is an error of constructor call that exceeded di.Timeout(). It matches context.DeadlineExceeded.
+type TimeoutError struct {
+	// Timeout is a limit of constructor call.
+	Timeout time.Duration
+}
+
+// Error is a string representation of error.
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timeout %s exceeded: %s", e.Timeout, context.DeadlineExceeded)
+}
+
+// Unwrap returns context.DeadlineExceeded.
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// sleep waits for duration or until context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -1,8 +1,11 @@
 package di
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+	"strings"
+	"sync"
 )
 
 // schema is a dependency injection schema.
@@ -17,17 +20,48 @@ type schema interface {
 type defaultSchema struct {
 	parents  []*defaultSchema
 	nodes    map[reflect.Type][]*node
-	cleanups []func()
+	cleanups []destructor
+	// registered nodes in registration order
+	registered []*node
+	// value-replacing decorators
+	wrappers []*wrapper
+	// declared named groups
+	groups map[string]bool
+	// policy of choosing between several definitions of the same type
+	policy DefaultPolicy
+	// emptyGroups allows groups without members
+	emptyGroups bool
+	// recoverPanics converts panics into errors
+	recoverPanics bool
+	// strict rejects untagged duplicate definitions
+	strict bool
+	// generation guards values of nodes and cleanups
+	generation *generation
+	// reloading serializes Reload() calls
+	reloading sync.Mutex
+	// sealed is a location where schema was sealed, prepared nodes of sealed schema are not checked again
+	sealed   *callerFrame
+	prepared sync.Map
+	// registered modules by name
+	modules map[string]*module
+	// condition of conditional options that are being registered
+	condition *condition
 }
 
 func (s *defaultSchema) cleanup(cleanup func()) {
-	s.cleanups = append(s.cleanups, cleanup)
+	g := s.generation.current()
+	g.Lock()
+	defer g.Unlock()
+	s.cleanups = append(s.cleanups, destructor{fn: cleanup})
 }
 
 // newDefaultSchema creates new dependency injection schema.
 func newDefaultSchema() *defaultSchema {
 	return &defaultSchema{
-		nodes: map[reflect.Type][]*node{},
+		nodes:      map[reflect.Type][]*node{},
+		groups:     map[string]bool{},
+		modules:    map[string]*module{},
+		generation: &generation{},
 	}
 }
 
@@ -35,6 +69,9 @@ func newDefaultSchema() *defaultSchema {
 // type []<type> for group.
 func (s *defaultSchema) register(n *node) {
 	defer tracer.Trace("Register %s", n)
+	n.owner = s
+	n.cond = s.condition
+	s.registered = append(s.registered, n)
 	if _, ok := s.nodes[n.rt]; !ok {
 		s.nodes[n.rt] = []*node{n}
 		return
@@ -42,8 +79,42 @@ func (s *defaultSchema) register(n *node) {
 	s.nodes[n.rt] = append(s.nodes[n.rt], n)
 }
 
-// used depth-first topological sort algorithm
-func (s *defaultSchema) prepare(n *node) error {
+// duplicate returns registered untagged definition of the node type which node conflicts with.
+func (s *defaultSchema) duplicate(n *node) (*node, bool) {
+	if len(n.tags) > 0 || n.primary || n.flatten || len(n.groups) > 0 {
+		return nil, false
+	}
+	for _, prev := range s.nodes[n.rt] {
+		// interfaces, types with di.Inject that are not provided and the container itself
+		if prev.origin != nil || prev.owner == nil || prev.self {
+			continue
+		}
+		if len(prev.tags) > 0 || prev.primary || prev.flatten || len(prev.groups) > 0 {
+			continue
+		}
+		// private definitions of different modules don't conflict
+		if (prev.private || n.private) && prev.module != n.module {
+			continue
+		}
+		// definitions of different conditional options may exclude each other
+		if prev.cond != s.condition {
+			continue
+		}
+		return prev, true
+	}
+	return nil, false
+}
+
+// decorate registers value-replacing decorator.
+func (s *defaultSchema) decorate(w *wrapper) {
+	defer tracer.Trace("Register %s decorator", w)
+	w.schema = s
+	w.cond = s.condition
+	s.wrappers = append(s.wrappers, w)
+}
+
+// prepare checks dependency graph of node, used depth-first topological sort algorithm
+func prepare(s schema, n *node) error {
 	var marks = map[*node]int{}
 	if err := visit(s, n, marks); err != nil {
 		return err
@@ -53,21 +124,56 @@ func (s *defaultSchema) prepare(n *node) error {
 
 // find finds provideFunc by its reflect.Type and Tags.
 func (s *defaultSchema) find(t reflect.Type, tags Tags) (*node, error) {
+	return s.lookup(t, tags, nil, nil)
+}
+
+// lookup finds node by its reflect.Type and Tags visible from the module. Nodes of conditional options are
+// found if their conditions are true, the chain holds conditions which dependencies are resolved.
+func (s *defaultSchema) lookup(t reflect.Type, tags Tags, m *module, chain *evaluation) (*node, error) {
 	nodes, ok := s.list(t)
 	// type found
 	if ok {
+		nodes, err := enabled(nodes, chain)
+		if err != nil {
+			return nil, err
+		}
+		nodes, private := visible(s.scoped(single(nodes)), m)
 		matched := matchTags(nodes, tags)
 		if len(matched) == 0 {
-			return nil, fmt.Errorf("type %s%s %w", t, tags, ErrTypeNotExists)
+			if hidden := matchTags(private, tags); len(hidden) > 0 {
+				return nil, &MissingError{Type: t, Tags: tags, Module: hidden[0].module.name}
+			}
+			return nil, s.missing(t, tags, m)
+		}
+		if len(matched) > 1 && len(tags) == 0 {
+			if n, ok := s.primary(matched); ok {
+				return n, nil
+			}
+			if n, ok := s.closest(matched); ok {
+				return n, nil
+			}
 		}
 		if len(matched) > 1 {
-			return nil, fmt.Errorf("multiple definitions of %s%s, maybe you need to use group type: []%s%s", t, tags, t, tags)
+			return nil, &AmbiguousError{Type: t, Tags: tags, Candidates: sources(matched)}
 		}
 		return matched[0], nil
 	}
+	if isLive(t) {
+		node := &node{
+			compiler: newLiveCompiler(t, tags),
+			rt:       t,
+			tags:     tags,
+			rv:       new(reflect.Value),
+		}
+		return node, nil
+	}
+	// context of resolution is background if it is not provided
+	if t == contextInterface && len(tags) == 0 {
+		return newContextNode(context.Background()), nil
+	}
 	// if not a group and not have di.Inject
-	if t.Kind() != reflect.Slice && !canInject(t) {
-		return nil, fmt.Errorf("type %s%s %w", t, tags, ErrTypeNotExists)
+	if t.Kind() != reflect.Slice && !isMapGroup(t) && !canInject(t) {
+		return nil, s.missing(t, tags, m)
 	}
 	if canInject(t) {
 		node := &node{
@@ -79,20 +185,44 @@ func (s *defaultSchema) find(t reflect.Type, tags Tags) (*node, error) {
 		s.nodes[t] = append(s.nodes[t], node)
 		return node, nil
 	}
-	return s.group(t, tags)
+	return s.group(t, tags, m, chain)
 }
 
-func (s *defaultSchema) group(t reflect.Type, tags Tags) (*node, error) {
-	group, ok := s.list(t.Elem())
-	if !ok {
-		return nil, fmt.Errorf("type %s%s %w", t, tags, ErrTypeNotExists)
+func (s *defaultSchema) group(t reflect.Type, tags Tags, m *module, chain *evaluation) (*node, error) {
+	filter, key := tags, ""
+	if isMapGroup(t) {
+		filter, key = mapGroupTags(tags)
+	}
+	var group []*node
+	var empty bool
+	if name, ok := filter[groupTag]; ok {
+		filter = filter.without(groupTag)
+		group = s.members(name, t.Elem())
+		empty = s.emptyGroups || s.declared(name)
+	} else {
+		group, _ = s.list(t.Elem())
+		empty = s.emptyGroups
+	}
+	group, err := enabled(group, chain)
+	if err != nil {
+		return nil, err
+	}
+	group, _ = visible(group, m)
+	matched := matchTags(group, filter)
+	if len(matched) == 0 && !empty {
+		return nil, &MissingError{Type: t, Tags: tags}
 	}
-	matched := matchTags(group, tags)
-	if len(matched) == 0 {
-		return nil, fmt.Errorf("type %s%s %w", t, tags, ErrTypeNotExists)
+	matched, err = sortGroup(matched)
+	if err != nil {
+		return nil, fmt.Errorf("%s%s: %w", t, tags, err)
+	}
+	if key != "" {
+		if err := checkGroupKeys(matched, key); err != nil {
+			return nil, fmt.Errorf("%s%s: %w", t, tags, err)
+		}
 	}
 	node := &node{
-		compiler: newGroupCompiler(t, matched),
+		compiler: newGroupCompiler(t, matched, key),
 		rt:       t,
 		tags:     tags,
 		rv:       new(reflect.Value),
@@ -100,6 +230,101 @@ func (s *defaultSchema) group(t reflect.Type, tags Tags) (*node, error) {
 	return node, nil
 }
 
+// wrappersOf returns value-replacing decorators of n. Decorators of ancestors go first.
+func (s *defaultSchema) wrappersOf(n *node, visited map[*defaultSchema]bool) (wrappers []*wrapper) {
+	if visited[s] {
+		return nil
+	}
+	visited[s] = true
+	for _, parent := range s.parents {
+		wrappers = append(wrappers, parent.wrappersOf(n, visited)...)
+	}
+	for _, w := range s.wrappers {
+		if w.match(n) {
+			wrappers = append(wrappers, w)
+		}
+	}
+	return wrappers
+}
+
+// members lists nodes of named group which types are assignable to t.
+func (s *defaultSchema) members(group string, t reflect.Type) (nodes []*node) {
+	for _, parent := range s.parents {
+		nodes = append(nodes, parent.members(group, t)...)
+	}
+	for _, n := range s.registered {
+		// interface nodes are skipped, provided node is checked instead
+		if n.origin != nil || !n.rt.AssignableTo(t) {
+			continue
+		}
+		for _, g := range n.groups {
+			if g == group {
+				nodes = append(nodes, n)
+				break
+			}
+		}
+	}
+	return nodes
+}
+
+// declared checks that named group is declared in schema or its ancestors.
+func (s *defaultSchema) declared(group string) bool {
+	if s.groups[group] {
+		return true
+	}
+	for _, parent := range s.parents {
+		if parent.declared(group) {
+			return true
+		}
+	}
+	return false
+}
+
+// single excludes flattened nodes that can be used as group members only.
+func single(nodes []*node) []*node {
+	result := make([]*node, 0, len(nodes))
+	for _, n := range nodes {
+		if !n.flatten {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// primary chooses the default node from several definitions of the same type.
+func (s *defaultSchema) primary(nodes []*node) (*node, bool) {
+	var primaries, untagged []*node
+	for _, n := range nodes {
+		if n.primary {
+			primaries = append(primaries, n)
+		}
+		if len(n.tags) == 0 {
+			untagged = append(untagged, n)
+		}
+	}
+	if len(primaries) == 1 {
+		return primaries[0], true
+	}
+	if len(primaries) == 0 && s.policy == PreferUntagged && len(untagged) == 1 {
+		return untagged[0], true
+	}
+	return nil, false
+}
+
+// candidates lists nodes with their tags and locations.
+func candidates(nodes []*node) string {
+	return strings.Join(sources(nodes), ", ")
+}
+
+// sources returns nodes with their tags and locations.
+func sources(nodes []*node) []string {
+	result := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		result = append(result, n.source())
+	}
+	return result
+}
+
 // list lists all the nodes of its reflect.Type
 func (s *defaultSchema) list(t reflect.Type) (nodes []*node, ok bool) {
 	for _, parent := range s.parents {
@@ -139,5 +364,6 @@ func (s *defaultSchema) addParent(parent *defaultSchema) error {
 		return fmt.Errorf("parent already chained")
 	}
 	s.parents = append(s.parents, parent)
+	s.generation.merge(parent.generation)
 	return nil
 }
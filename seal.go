@@ -0,0 +1,69 @@
+package di
+
+import (
+	"fmt"
+)
+
+// SealedError is an error of registration in the sealed container, see container.Seal().
+type SealedError struct {
+	// Op is a rejected operation: apply, provide, decorate, intercept or add parent.
+	Op string
+	// Frame is a location where container was sealed.
+	Frame string
+}
+
+// Error is a string representation of error.
+func (e *SealedError) Error() string {
+	return fmt.Sprintf("%s is rejected, container is sealed at %s", e.Op, e.Frame)
+}
+
+// Seal freezes the dependency graph of container. Registrations in the sealed container fail with
+// *di.SealedError, and the dependency graph of each resolved type is checked only once:
+//
+//	container, err := di.New(options...)
+//	if err != nil {
+//		// handle error
+//	}
+//	container.Seal()
+//
+// Types are still resolved, invalidated and reloaded. Sealing again has no effect.
+func (c *Container) Seal() {
+	c.schema.seal(stacktrace(0))
+}
+
+// Sealed returns container option that seals container after all options are applied, see container.Seal().
+func Sealed() Option {
+	frame := stacktrace(0)
+	return option(func(c *diopts) {
+		c.sealed = &frame
+	})
+}
+
+// seal freezes schema, sealing location is kept from the first call.
+func (s *defaultSchema) seal(frame callerFrame) {
+	if s.sealed != nil {
+		return
+	}
+	s.sealed = &frame
+}
+
+// frozen returns true if schema and its ancestors are sealed, their dependency graph can't change.
+func (s *defaultSchema) frozen() bool {
+	if s.sealed == nil {
+		return false
+	}
+	for _, parent := range s.parents {
+		if !parent.frozen() {
+			return false
+		}
+	}
+	return true
+}
+
+// checkSealed returns *SealedError if container is sealed.
+func (c *Container) checkSealed(op string) error {
+	if c.schema.sealed == nil {
+		return nil
+	}
+	return &SealedError{Op: op, Frame: fmt.Sprint(*c.schema.sealed)}
+}
@@ -0,0 +1,50 @@
+package di
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// missing returns error of missing type, suggestions are found only if the error is reported.
+func (s *defaultSchema) missing(t reflect.Type, tags Tags, m *module) *MissingError {
+	return &MissingError{Type: t, Tags: tags, suggest: func() []string {
+		return s.suggest(t, tags, m)
+	}}
+}
+
+// suggest finds definitions that are probably meant by the missing type: the same type with other tags,
+// the pointer or non-pointer variant and implementations of the interface.
+func (s *defaultSchema) suggest(t reflect.Type, tags Tags, m *module) (suggestions []string) {
+	if nodes, ok := s.list(t); ok {
+		nodes, _ = visible(single(nodes), m)
+		for _, n := range nodes {
+			if n.cond.disabled() {
+				suggestions = append(suggestions, n.source())
+				continue
+			}
+			suggestions = append(suggestions, fmt.Sprintf("%s with other tags", n.source()))
+		}
+	}
+	variant, hint := reflect.PtrTo(t), "pointer"
+	if t.Kind() == reflect.Ptr {
+		variant, hint = t.Elem(), "not a pointer"
+	}
+	if nodes, ok := s.list(variant); ok {
+		nodes, _ = visible(single(nodes), m)
+		for _, n := range matchTags(nodes, tags) {
+			suggestions = append(suggestions, fmt.Sprintf("%s, %s", n.source(), hint))
+		}
+	}
+	if t.Kind() == reflect.Interface {
+		for _, schema := range s.ancestry() {
+			nodes, _ := visible(single(schema.registered), m)
+			for _, n := range matchTags(nodes, tags) {
+				if n.origin != nil || n.rt.Kind() == reflect.Interface || !n.rt.Implements(t) {
+					continue
+				}
+				suggestions = append(suggestions, fmt.Sprintf("%s, provide it with di.As(new(%s))", n.source(), t))
+			}
+		}
+	}
+	return suggestions
+}
@@ -53,6 +53,12 @@ func (t Tags) applyResolve(params *ResolveParams) {
 	}
 }
 
+func (t Tags) selector() (func(rt reflect.Type, tags Tags) bool, error) {
+	return func(rt reflect.Type, tags Tags) bool {
+		return tags.match(t)
+	}, nil
+}
+
 // String is a tags string representation.
 func (t Tags) String() string {
 	var keys []string
@@ -69,6 +75,17 @@ func (t Tags) String() string {
 	return "[" + strings.Join(keys, ";") + "]"
 }
 
+// without returns copy of tags without key.
+func (t Tags) without(key string) Tags {
+	result := make(Tags, len(t))
+	for k, v := range t {
+		if k != key {
+			result[k] = v
+		}
+	}
+	return result
+}
+
 // match checks that all of key value pairs exists in t. Not equal.
 func (t Tags) match(tags Tags) bool {
 	for k, v := range tags {
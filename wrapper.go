@@ -0,0 +1,185 @@
+package di
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Wrapper is a function that replaces resolved value with a new one of the same type, for example,
+// with a caching or metrics layer around an interface:
+//
+//	func NewCachedRepository(orig Repository, cache *Cache) (Repository, error) {
+//		return &CachedRepository{Repository: orig, cache: cache}, nil
+//	}
+//
+// The first argument is an original value. Other arguments are dependencies that will be resolved
+// automatically. The wrapper returns a new value of the same type and optional error. The wrapper
+// that returns only error checks the value without replacing it:
+//
+//	func ValidateConfig(config Validatable) error {
+//		return config.Validate()
+//	}
+type Wrapper interface{}
+
+// wrapperType describes types of wrapper functions.
+type wrapperType int
+
+const (
+	wrapperUnknown    wrapperType = iota
+	wrapperValue                  // (orig, deps) (result)
+	wrapperValueError             // (orig, deps) (result, error)
+	wrapperError                  // (orig, deps) (error)
+)
+
+// wrapper is a value-replacing decorator.
+type wrapper struct {
+	fn  function
+	typ wrapperType
+	// selector of decorated nodes
+	selector func(rt reflect.Type, tags Tags) bool
+	// schema and module where wrapper was registered, dependencies are resolved from the module
+	schema *defaultSchema
+	module *module
+	frame  callerFrame
+	// cond is a condition of conditional options where wrapper was registered
+	cond *condition
+}
+
+// newWrapper creates value-replacing decorator from function.
+func newWrapper(w Wrapper, selector func(rt reflect.Type, tags Tags) bool) (*wrapper, error) {
+	if w == nil {
+		return nil, &SignatureError{Kind: "decorator"}
+	}
+	fn, valid := inspectFunction(w)
+	if !valid {
+		return nil, &SignatureError{Kind: "decorator", Type: reflect.TypeOf(w)}
+	}
+	typ := determineWrapperType(fn)
+	if typ == wrapperUnknown {
+		return nil, &SignatureError{Kind: "decorator", Type: fn.Type}
+	}
+	return &wrapper{
+		fn:       fn,
+		typ:      typ,
+		selector: selector,
+	}, nil
+}
+
+// String is a string representation of wrapper.
+func (w *wrapper) String() string {
+	if w.frame.file == "" {
+		return w.fn.Name
+	}
+	return fmt.Sprintf("%s at %s", w.fn.Name, w.frame)
+}
+
+// match checks that wrapper decorates node. Node type must be assignable to wrapper argument, and
+// wrapper result must be assignable to node type.
+func (w *wrapper) match(n *node) bool {
+	if !n.rt.AssignableTo(w.fn.In(0)) {
+		return false
+	}
+	if w.typ != wrapperError && !w.fn.Out(0).AssignableTo(n.rt) {
+		return false
+	}
+	return w.selector(n.rt, n.tags)
+}
+
+// deps returns dependencies of wrapper that decorates value resolved in schema.
+func (w *wrapper) deps(s schema) (deps []*node, err error) {
+	s = inWrapper(s, w)
+	names := paramNames(w.fn)
+	for i := 1; i < w.fn.NumIn(); i++ {
+		node, err := findParam(s, w.fn.In(i), paramName(names, i))
+		if err != nil {
+			return nil, err
+		}
+		deps = append(deps, node)
+	}
+	return deps, nil
+}
+
+// wrap replaces value resolved in schema with result of wrapper function.
+func (w *wrapper) wrap(s schema, rv reflect.Value) (reflect.Value, error) {
+	nodes, err := w.deps(s)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	args := []reflect.Value{rv}
+	for _, node := range nodes {
+		v, err := node.Value(inWrapper(s, w))
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%s: %w", node, err)
+		}
+		args = append(args, v)
+	}
+	out := funcResult(w.fn.Call(args))
+	switch w.typ {
+	case wrapperValue:
+		return out.value(), nil
+	case wrapperValueError:
+		return out.value(), out.error(1)
+	case wrapperError:
+		return rv, out.error(0)
+	}
+	bug()
+	return reflect.Value{}, nil
+}
+
+// determineWrapperType
+func determineWrapperType(fn function) wrapperType {
+	if fn.NumIn() == 0 || fn.NumOut() == 0 {
+		return wrapperUnknown
+	}
+	switch true {
+	case fn.NumOut() == 1 && fn.Out(0) == fn.In(0):
+		return wrapperValue
+	case fn.NumOut() == 1 && isError(fn.Out(0)):
+		return wrapperError
+	case fn.NumOut() == 2 && fn.Out(0) == fn.In(0) && isError(fn.Out(1)):
+		return wrapperValueError
+	}
+	return wrapperUnknown
+}
+
+// Matcher selects types for container-wide decorators. See Container.DecorateAll().
+// The di.Tags is a matcher of types with matching tags.
+type Matcher interface {
+	selector() (func(rt reflect.Type, tags Tags) bool, error)
+}
+
+// OfType returns matcher of exact type. The argument is a pointer to type:
+//
+//	di.OfType(new(*Config))
+func OfType(ptr Pointer) Matcher {
+	return matcher(func() (func(rt reflect.Type, tags Tags) bool, error) {
+		if ptr == nil || reflect.TypeOf(ptr).Kind() != reflect.Ptr {
+			return nil, fmt.Errorf("type matcher must be a pointer, got %s", reflect.TypeOf(ptr))
+		}
+		typ := reflect.TypeOf(ptr).Elem()
+		return func(rt reflect.Type, tags Tags) bool {
+			return rt == typ
+		}, nil
+	})
+}
+
+// Implements returns matcher of types that implement interface. The argument is a pointer to interface:
+//
+//	di.Implements(new(http.Handler))
+func Implements(i Interface) Matcher {
+	return matcher(func() (func(rt reflect.Type, tags Tags) bool, error) {
+		link, err := inspectInterfacePointer(i)
+		if err != nil {
+			return nil, err
+		}
+		return func(rt reflect.Type, tags Tags) bool {
+			return rt.Implements(link.Type)
+		}, nil
+	})
+}
+
+type matcher func() (func(rt reflect.Type, tags Tags) bool, error)
+
+func (m matcher) selector() (func(rt reflect.Type, tags Tags) bool, error) {
+	return m()
+}